@@ -3,28 +3,62 @@ package main
 import (
 	"context"
 	"errors"
+	"net"
+	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // IANA time zones of analytics queries
 
 	"github.com/go-playground/validator/v10"
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
 
 	"github.com/aliskhannn/url-shortener/internal/api/handlers/analytics"
+	"github.com/aliskhannn/url-shortener/internal/api/handlers/apikey"
+	"github.com/aliskhannn/url-shortener/internal/api/handlers/domain"
 	"github.com/aliskhannn/url-shortener/internal/api/handlers/link"
+	"github.com/aliskhannn/url-shortener/internal/api/handlers/workspace"
 	"github.com/aliskhannn/url-shortener/internal/api/router"
 	"github.com/aliskhannn/url-shortener/internal/api/server"
+	"github.com/aliskhannn/url-shortener/internal/botdetect"
 	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/geoip"
+	"github.com/aliskhannn/url-shortener/internal/live"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
 	analyticsrepo "github.com/aliskhannn/url-shortener/internal/repository/analytics"
+	apikeyrepo "github.com/aliskhannn/url-shortener/internal/repository/apikey"
+	domainrepo "github.com/aliskhannn/url-shortener/internal/repository/domain"
 	linkrepo "github.com/aliskhannn/url-shortener/internal/repository/link"
+	workspacerepo "github.com/aliskhannn/url-shortener/internal/repository/workspace"
 	analyticssvc "github.com/aliskhannn/url-shortener/internal/service/analytics"
+	apikeysvc "github.com/aliskhannn/url-shortener/internal/service/apikey"
+	domainsvc "github.com/aliskhannn/url-shortener/internal/service/domain"
 	linksvc "github.com/aliskhannn/url-shortener/internal/service/link"
+	workspacesvc "github.com/aliskhannn/url-shortener/internal/service/workspace"
+	"github.com/aliskhannn/url-shortener/internal/visitor"
+	"github.com/aliskhannn/url-shortener/internal/worker/clickstream"
+	"github.com/aliskhannn/url-shortener/internal/worker/ingest"
+	"github.com/aliskhannn/url-shortener/internal/worker/partitions"
+	"github.com/aliskhannn/url-shortener/internal/worker/sweeper"
+)
+
+// Modes the binary runs in, selected by the first argument.
+const (
+	modeServer = "server" // serve the HTTP API, the default
+	modeWorker = "worker" // save click events of the Redis Stream
 )
 
 func main() {
+	mode := modeServer
+	if len(os.Args) > 1 {
+		mode = os.Args[1]
+	}
+
 	// Setup context to handle SIGINT and SIGTERM for graceful shutdown.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -34,6 +68,10 @@ func main() {
 	cfg := config.Must()
 	val := validator.New()
 
+	if mode != modeServer && mode != modeWorker {
+		zlog.Logger.Fatal().Str("mode", mode).Msg("unknown mode, expected server or worker")
+	}
+
 	// Connect to PostgreSQL master and slave databases.
 	opts := &dbpg.Options{
 		MaxOpenConns:    cfg.Database.MaxOpenConns,
@@ -65,17 +103,95 @@ func main() {
 		zlog.Logger.Fatal().Err(err).Msg("failed to connect to redis")
 	}
 
-	// Initialize link and analytics repository, service and handlers.
+	// Open GeoIP databases and watch them for updates.
+	geo, err := geoip.New(cfg.GeoIP)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to open geoip databases")
+	}
+	defer geo.Close()
+
+	go geo.Run(ctx)
+
+	// Compile rules telling bots from human visitors.
+	bots, err := botdetect.New(cfg.Bots)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to create bot classifier")
+	}
+
+	// Initialize link, analytics, API key, workspace and domain repository, service and handlers.
 	linkRepo := linkrepo.NewRepository(db)
 	analyticsRepo := analyticsrepo.NewRepository(db)
+	apiKeyRepo := apikeyrepo.NewRepository(db)
+	workspaceRepo := workspacerepo.NewRepository(db)
+	domainRepo := domainrepo.NewRepository(db)
+
+	linkService := linksvc.NewService(linkRepo, rdb, cfg.Server.ReservedAliases)
+	// Publish saved clicks to live subscribers of all instances.
+	analyticsService := analyticssvc.NewService(analyticsRepo, rdb, geo, visitor.New(rdb), live.NewPublisher(rdb))
+	apiKeyService := apikeysvc.NewService(apiKeyRepo)
+	workspaceService := workspacesvc.NewService(workspaceRepo)
+	domainService := domainsvc.NewService(domainRepo, linkRepo, net.DefaultResolver)
+
+	// In worker mode, save click events of the stream until shutdown.
+	if mode == modeWorker {
+		consumer := clickstream.NewConsumer(rdb, analyticsService, cfg.Stream, cfg.Retry)
+		if err := consumer.Run(ctx); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to consume click events")
+		}
 
-	linkService := linksvc.NewService(linkRepo, rdb)
-	analyticsService := analyticssvc.NewService(analyticsRepo, rdb)
+		zlog.Logger.Info().Msg("worker stopped")
+		closeDB(db)
+		return
+	}
+
+	// Start background sweeper of expired links.
+	go sweeper.New(linkService, cfg.Sweeper).Run(ctx)
 
-	linkHandler := link.NewHandler(ctx, cfg, val, linkService, analyticsService)
-	analyticsHandler := analytics.NewHandler(analyticsService, cfg)
+	// Deliver live clicks to subscribers, closing their streams on shutdown.
+	liveClicks := live.New(rdb)
+	go liveClicks.Run(ctx)
 
-	r := router.New(linkHandler, analyticsHandler)
+	// Start background manager of monthly partitions of clicks.
+	go partitions.New(analyticsService, cfg.Partitions).Run(ctx)
+
+	// Start workers preparing click events in batches and saving them, or appending them to
+	// the stream which saves them directly if it is unavailable.
+	var clickStore interface {
+		SaveAnalyticsBatch(ctx context.Context, strategy retry.Strategy, events []model.Analytics) error
+	} = analyticsService
+	if cfg.Stream.Enabled {
+		clickStore = clickstream.NewProducer(rdb, cfg.Stream, analyticsService)
+	}
+
+	clicks := ingest.New(analyticsService, clickStore, cfg.Ingest, cfg.Retry)
+	clicks.Start()
+
+	handlers := router.Handlers{
+		Link:      link.NewHandler(cfg, val, linkService, clicks, workspaceService, domainService, geo, bots),
+		Analytics: analytics.NewHandler(analyticsService, linkService, liveClicks, cfg),
+		APIKey:    apikey.NewHandler(val, apiKeyService),
+		Workspace: workspace.NewHandler(val, workspaceService),
+		Domain:    domain.NewHandler(val, domainService),
+	}
+
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to parse trusted proxies")
+	}
+
+	clientIPHeader, err := middleware.ParseClientIPHeader(cfg.Server.ClientIPHeader)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to parse client ip header")
+	}
+
+	middlewares := router.Middlewares{
+		ClientIP:  middleware.ClientIPMiddleware(trustedProxies, clientIPHeader),
+		Auth:      middleware.AuthMiddleware(apiKeyService),
+		Admin:     middleware.AdminMiddleware(cfg.Auth.AdminToken),
+		Workspace: middleware.WorkspaceMiddleware(workspaceService),
+	}
+
+	r := router.New(handlers, middlewares, cfg.Server)
 	s := server.New(cfg.Server.HTTPPort, r)
 	go func() {
 		if err := s.ListenAndServe(); err != nil {
@@ -99,7 +215,20 @@ func main() {
 		zlog.Logger.Info().Msg("timeout exceeded, forcing shutdown")
 	}
 
-	// Close master and slave databases.
+	// Save click events still queued.
+	zlog.Logger.Info().Msg("flushing click events")
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.Ingest.WriteTimeout)
+	defer cancelFlush()
+
+	if err := clicks.Close(flushCtx); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to flush click events")
+	}
+
+	closeDB(db)
+}
+
+// closeDB closes master and slave databases.
+func closeDB(db *dbpg.DB) {
 	if err := db.Master.Close(); err != nil {
 		zlog.Logger.Printf("failed to close master DB: %v", err)
 	}
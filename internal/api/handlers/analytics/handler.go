@@ -2,39 +2,70 @@ package analytics
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
 
 	"github.com/aliskhannn/url-shortener/internal/api/respond"
 	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	linkrepo "github.com/aliskhannn/url-shortener/internal/repository/link"
 	analyticssvc "github.com/aliskhannn/url-shortener/internal/service/analytics"
+	domainsvc "github.com/aliskhannn/url-shortener/internal/service/domain"
 )
 
 // analyticsService defines the interface that the Handler depends on.
 type analyticsService interface {
-	GetAnalyticsSummary(ctx context.Context, strategy retry.Strategy, alias string) (*analyticssvc.SummaryOfAnalytics, error)
+	GetAnalyticsSummary(
+		ctx context.Context, strategy retry.Strategy, link model.Link, q analyticssvc.Query,
+	) (*analyticssvc.SummaryOfAnalytics, error)
+	EraseIP(ctx context.Context, ip string) (int64, error)
+}
+
+// linkService defines the interface that the Handler depends on.
+type linkService interface {
+	GetLink(ctx context.Context, key model.LinkKey) (model.Link, error)
+}
+
+// liveClicks defines the interface that the Handler depends on.
+type liveClicks interface {
+	Subscribe(ctx context.Context, linkID uuid.UUID) (<-chan model.LiveClick, func(), error)
 }
 
 // Handler handles HTTP requests related to link.
 type Handler struct {
 	analyticsService analyticsService
+	linkService      linkService
+	live             liveClicks
 	cfg              *config.Config
 }
 
 // NewHandler creates a new Handler instance.
 func NewHandler(
 	as analyticsService,
+	ls linkService,
+	live liveClicks,
 	cfg *config.Config,
 ) *Handler {
-	return &Handler{analyticsService: as, cfg: cfg}
+	return &Handler{analyticsService: as, linkService: ls, live: live, cfg: cfg}
 }
 
 // GetAnalytics handles GET /analytics/:alias requests.
-// It retrieves analytics summary (total clicks, clicks by day, clicks by user agent) for a given alias.
+// It retrieves analytics summary (total clicks, click time series and breakdowns) for a given alias.
+// The link is looked up within the workspace of the request and the optional domain query parameter.
+// Clicks can be limited with the from and to query parameters, the time series is laid out
+// by the granularity and tz query parameters. Clicks per raw user agent are included
+// if user_agents is true, bot clicks are only counted if include_bots is true.
 func (h *Handler) GetAnalytics(c *ginext.Context) {
 	alias := c.Param("alias")
 	if alias == "" {
@@ -44,8 +75,26 @@ func (h *Handler) GetAnalytics(c *ginext.Context) {
 		return
 	}
 
-	summary, err := h.analyticsService.GetAnalyticsSummary(c.Request.Context(), h.cfg.Retry, alias)
+	q, err := parseQuery(c)
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Str("alias", alias).Msg("invalid analytics query")
+		respond.Fail(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	link, ok := h.resolveLink(c, alias)
+	if !ok {
+		return
+	}
+
+	summary, err := h.analyticsService.GetAnalyticsSummary(c.Request.Context(), h.cfg.Retry, link, q)
 	if err != nil {
+		if errors.Is(err, analyticssvc.ErrRangeTooLarge) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("analytics range too large")
+			respond.Fail(c.Writer, http.StatusBadRequest, err)
+			return
+		}
+
 		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to get link analytics")
 		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 		return
@@ -53,3 +102,207 @@ func (h *Handler) GetAnalytics(c *ginext.Context) {
 
 	respond.JSON(c.Writer, http.StatusOK, summary)
 }
+
+// liveHeartbeat is how often a comment is sent over idle live streams to keep them open.
+const liveHeartbeat = 15 * time.Second
+
+// LiveClicks handles GET /analytics/:alias/live requests.
+// It streams human clicks on the link as Server-Sent Events named click with a JSON
+// model.LiveClick as data, until the client disconnects or the server shuts down.
+func (h *Handler) LiveClicks(c *ginext.Context) {
+	alias := c.Param("alias")
+
+	link, ok := h.resolveLink(c, alias)
+	if !ok {
+		return
+	}
+
+	clicks, cancel, err := h.live.Subscribe(c.Request.Context(), link.ID)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to subscribe to live clicks")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+	defer cancel()
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no") // disable buffering by nginx
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		case click, ok := <-clicks:
+			if !ok {
+				return // server shutdown
+			}
+
+			b, err := json.Marshal(click)
+			if err != nil {
+				zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to encode live click")
+				continue
+			}
+
+			if _, err := fmt.Fprintf(c.Writer, "event: click\ndata: %s\n\n", b); err != nil {
+				return
+			}
+		}
+
+		c.Writer.Flush()
+	}
+}
+
+// resolveLink looks up the link of the alias within the workspace of the request and the
+// optional domain query parameter. It writes an error response and returns false on failure.
+func (h *Handler) resolveLink(c *ginext.Context, alias string) (model.Link, bool) {
+	key := model.LinkKey{
+		WorkspaceID: middleware.Workspace(c).ID,
+		Domain:      domainsvc.NormalizeHost(c.Query("domain")),
+		Alias:       alias,
+		OwnerID:     middleware.LinkOwner(c),
+	}
+
+	link, err := h.linkService.GetLink(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, linkrepo.ErrAliasNotFound) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("alias not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+			return model.Link{}, false
+		}
+
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to get link")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return model.Link{}, false
+	}
+
+	return link, true
+}
+
+// EraseRequest represents the expected JSON payload of a data subject erasure request.
+type EraseRequest struct {
+	IP string `json:"ip"`
+}
+
+// EraseResponse represents the JSON response to a data subject erasure request.
+type EraseResponse struct {
+	Deleted int64 `json:"deleted"` // number of deleted clicks
+}
+
+// EraseIP handles POST /admin/privacy/erasure requests.
+// It deletes clicks of all workspaces made from the IP of the request body.
+func (h *Handler) EraseIP(c *ginext.Context) {
+	var req EraseRequest
+
+	// Decode JSON request body into EraseRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	ip := net.ParseIP(req.IP)
+	if ip == nil {
+		zlog.Logger.Warn().Msg("invalid ip of erasure request")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid ip"))
+		return
+	}
+
+	n, err := h.analyticsService.EraseIP(c.Request.Context(), ip.String())
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to erase clicks by ip")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	zlog.Logger.Info().Int64("count", n).Msg("erased clicks by ip")
+	respond.JSON(c.Writer, http.StatusOK, EraseResponse{Deleted: n})
+}
+
+// dateLayout is the layout of whole-day bounds of the time range.
+const dateLayout = "2006-01-02"
+
+// parseQuery parses the summary query from the query parameters: from and to as
+// RFC 3339 timestamps or dates in the tz time zone, where a date as to includes the
+// whole day, granularity (day by default) and tz as an IANA time zone (UTC by default).
+func parseQuery(c *ginext.Context) (analyticssvc.Query, error) {
+	q := analyticssvc.DefaultQuery()
+
+	if g := c.Query("granularity"); g != "" {
+		q.Granularity = model.Granularity(g)
+		if !q.Granularity.IsValid() {
+			return q, fmt.Errorf("invalid granularity: must be minute, hour, day, week or month")
+		}
+	}
+
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			return q, fmt.Errorf("invalid tz: must be an IANA time zone")
+		}
+		q.Location = loc
+	}
+
+	if ua := c.Query("user_agents"); ua != "" {
+		v, err := strconv.ParseBool(ua)
+		if err != nil {
+			return q, fmt.Errorf("invalid user_agents: must be a boolean")
+		}
+		q.UserAgents = v
+	}
+
+	if bots := c.Query("include_bots"); bots != "" {
+		v, err := strconv.ParseBool(bots)
+		if err != nil {
+			return q, fmt.Errorf("invalid include_bots: must be a boolean")
+		}
+		q.Filter.IncludeBots = v
+	}
+
+	var err error
+	if q.Filter.From, err = parseBound(c.Query("from"), q.Location, false); err != nil {
+		return q, fmt.Errorf("invalid from: must be an RFC 3339 timestamp or a date")
+	}
+	if q.Filter.To, err = parseBound(c.Query("to"), q.Location, true); err != nil {
+		return q, fmt.Errorf("invalid to: must be an RFC 3339 timestamp or a date")
+	}
+
+	if !q.Filter.From.IsZero() && !q.Filter.To.IsZero() && !q.Filter.From.Before(q.Filter.To) {
+		return q, fmt.Errorf("invalid range: from must be before to")
+	}
+
+	return q, nil
+}
+
+// parseBound parses a bound of the time range, a zero time if the value is empty.
+// A date is midnight in the time zone, of the next day if end is set.
+func parseBound(v string, loc *time.Location, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(dateLayout, v, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,155 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/api/respond"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	apikeyrepo "github.com/aliskhannn/url-shortener/internal/repository/apikey"
+)
+
+// apiKeyService defines the interface that the Handler depends on.
+type apiKeyService interface {
+	CreateKey(ctx context.Context, ownerID uuid.UUID, name string) (model.APIKey, string, error)
+	ListKeys(ctx context.Context, ownerID uuid.UUID) ([]model.APIKey, error)
+	RevokeKey(ctx context.Context, ownerID, id uuid.UUID) error
+}
+
+// Handler handles HTTP requests related to API keys.
+type Handler struct {
+	validator     *validator.Validate
+	apiKeyService apiKeyService
+}
+
+// NewHandler creates a new Handler instance.
+func NewHandler(v *validator.Validate, ks apiKeyService) *Handler {
+	return &Handler{validator: v, apiKeyService: ks}
+}
+
+// CreateRequest represents the expected JSON payload for creating an API key.
+type CreateRequest struct {
+	Name string `json:"name" validate:"max=64"`
+}
+
+// IssueRequest represents the expected JSON payload for issuing an API key by an administrator.
+// If OwnerID is omitted, the key is issued for a new owner.
+type IssueRequest struct {
+	OwnerID *uuid.UUID `json:"owner_id"`
+	Name    string     `json:"name" validate:"max=64"`
+}
+
+// CreateResponse represents a newly created API key.
+// The plaintext key is returned only once and cannot be retrieved later.
+type CreateResponse struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+// CreateKey handles POST /keys requests.
+// It creates a new API key for the authenticated owner.
+func (h *Handler) CreateKey(c *ginext.Context) {
+	var req CreateRequest
+
+	// Decode JSON request body into CreateRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	h.createKey(c, middleware.OwnerID(c), req.Name)
+}
+
+// IssueKey handles POST /admin/keys requests.
+// It creates a new API key for the given or a new owner.
+func (h *Handler) IssueKey(c *ginext.Context) {
+	var req IssueRequest
+
+	// Decode JSON request body into IssueRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	ownerID := uuid.New()
+	if req.OwnerID != nil {
+		ownerID = *req.OwnerID
+	}
+
+	h.createKey(c, ownerID, req.Name)
+}
+
+// createKey creates an API key for the owner and writes it to the response.
+func (h *Handler) createKey(c *ginext.Context, ownerID uuid.UUID, name string) {
+	key, token, err := h.apiKeyService.CreateKey(c.Request.Context(), ownerID, name)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("owner_id", ownerID.String()).Msg("failed to create api key")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.Created(c.Writer, CreateResponse{APIKey: key, Key: token})
+}
+
+// ListKeys handles GET /keys requests.
+// It returns all API keys of the authenticated owner.
+func (h *Handler) ListKeys(c *ginext.Context) {
+	ownerID := middleware.OwnerID(c)
+
+	keys, err := h.apiKeyService.ListKeys(c.Request.Context(), ownerID)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("owner_id", ownerID.String()).Msg("failed to list api keys")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, keys)
+}
+
+// RevokeKey handles DELETE /keys/:id requests.
+// It revokes an API key of the authenticated owner.
+func (h *Handler) RevokeKey(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("invalid api key id")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid api key id"))
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(c.Request.Context(), middleware.OwnerID(c), id); err != nil {
+		if errors.Is(err, apikeyrepo.ErrKeyNotFound) {
+			zlog.Logger.Warn().Str("id", id.String()).Msg("api key not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("api key not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("id", id.String()).Msg("failed to revoke api key")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.NoContent(c.Writer)
+}
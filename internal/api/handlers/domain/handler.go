@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/api/respond"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	domainrepo "github.com/aliskhannn/url-shortener/internal/repository/domain"
+	domainsvc "github.com/aliskhannn/url-shortener/internal/service/domain"
+)
+
+// domainService defines the interface that the Handler depends on.
+type domainService interface {
+	RegisterDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error)
+	ListDomains(ctx context.Context, workspaceID uuid.UUID) ([]model.Domain, error)
+	VerifyDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error)
+	DeleteDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) error
+}
+
+// Handler handles HTTP requests related to custom domains.
+type Handler struct {
+	validator     *validator.Validate
+	domainService domainService
+}
+
+// NewHandler creates a new Handler instance.
+func NewHandler(v *validator.Validate, ds domainService) *Handler {
+	return &Handler{validator: v, domainService: ds}
+}
+
+// RegisterRequest represents the expected JSON payload for registering a domain.
+type RegisterRequest struct {
+	Hostname string `json:"hostname" validate:"required"`
+}
+
+// DomainResponse represents a domain together with the DNS record proving its ownership.
+type DomainResponse struct {
+	model.Domain
+	TXTRecord string `json:"txt_record"` // name of the TXT record to create
+	TXTValue  string `json:"txt_value"`  // value of the TXT record to create
+}
+
+// newDomainResponse wraps the domain into a DomainResponse.
+func newDomainResponse(d model.Domain) DomainResponse {
+	return DomainResponse{Domain: d, TXTRecord: d.VerificationRecord(), TXTValue: d.VerificationValue()}
+}
+
+// RegisterDomain handles POST /domains requests.
+// It registers a custom domain for the current workspace and returns the DNS record to verify it.
+func (h *Handler) RegisterDomain(c *ginext.Context) {
+	var req RegisterRequest
+
+	// Decode JSON request body into RegisterRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	d, err := h.domainService.RegisterDomain(c.Request.Context(), middleware.Workspace(c).ID, req.Hostname)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainsvc.ErrInvalidHostname):
+			zlog.Logger.Warn().Str("hostname", req.Hostname).Msg("invalid hostname")
+			respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid hostname"))
+		case errors.Is(err, domainrepo.ErrDomainExists):
+			zlog.Logger.Warn().Str("hostname", req.Hostname).Msg("domain already registered")
+			respond.Fail(c.Writer, http.StatusConflict, fmt.Errorf("domain already registered"))
+		default:
+			zlog.Logger.Error().Err(err).Str("hostname", req.Hostname).Msg("failed to register domain")
+			respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	respond.Created(c.Writer, newDomainResponse(d))
+}
+
+// ListDomains handles GET /domains requests.
+// It returns all domains of the current workspace.
+func (h *Handler) ListDomains(c *ginext.Context) {
+	domains, err := h.domainService.ListDomains(c.Request.Context(), middleware.Workspace(c).ID)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to list domains")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	res := make([]DomainResponse, 0, len(domains))
+	for _, d := range domains {
+		res = append(res, newDomainResponse(d))
+	}
+
+	respond.OK(c.Writer, res)
+}
+
+// VerifyDomain handles POST /domains/:hostname/verify requests.
+// It checks the DNS TXT record of the domain and marks the domain as verified.
+func (h *Handler) VerifyDomain(c *ginext.Context) {
+	hostname := c.Param("hostname")
+
+	d, err := h.domainService.VerifyDomain(c.Request.Context(), middleware.Workspace(c).ID, hostname)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainrepo.ErrDomainNotFound):
+			zlog.Logger.Warn().Str("hostname", hostname).Msg("domain not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("domain not found"))
+		case errors.Is(err, domainsvc.ErrVerificationFailed):
+			zlog.Logger.Warn().Str("hostname", hostname).Msg("domain verification failed")
+			respond.Fail(c.Writer, http.StatusUnprocessableEntity, fmt.Errorf("verification record not found"))
+		case errors.Is(err, domainrepo.ErrDomainTaken):
+			zlog.Logger.Warn().Str("hostname", hostname).Msg("domain verified by another workspace")
+			respond.Fail(c.Writer, http.StatusConflict, fmt.Errorf("domain verified by another workspace"))
+		default:
+			zlog.Logger.Error().Err(err).Str("hostname", hostname).Msg("failed to verify domain")
+			respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	respond.OK(c.Writer, newDomainResponse(d))
+}
+
+// DeleteDomain handles DELETE /domains/:hostname requests.
+// It deletes a domain of the current workspace which serves no links.
+func (h *Handler) DeleteDomain(c *ginext.Context) {
+	hostname := c.Param("hostname")
+
+	if err := h.domainService.DeleteDomain(c.Request.Context(), middleware.Workspace(c).ID, hostname); err != nil {
+		switch {
+		case errors.Is(err, domainrepo.ErrDomainNotFound):
+			zlog.Logger.Warn().Str("hostname", hostname).Msg("domain not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("domain not found"))
+		case errors.Is(err, domainsvc.ErrDomainInUse):
+			zlog.Logger.Warn().Str("hostname", hostname).Msg("domain has links")
+			respond.Fail(c.Writer, http.StatusConflict, fmt.Errorf("domain has links"))
+		default:
+			zlog.Logger.Error().Err(err).Str("hostname", hostname).Msg("failed to delete domain")
+			respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	respond.NoContent(c.Writer)
+}
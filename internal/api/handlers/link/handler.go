@@ -7,6 +7,11 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -14,49 +19,162 @@ import (
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
+	"golang.org/x/text/language"
 
 	"github.com/aliskhannn/url-shortener/internal/api/respond"
 	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/geoip"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
 	"github.com/aliskhannn/url-shortener/internal/model"
+	"github.com/aliskhannn/url-shortener/internal/qrcode"
+	domainrepo "github.com/aliskhannn/url-shortener/internal/repository/domain"
 	linkrepo "github.com/aliskhannn/url-shortener/internal/repository/link"
+	workspacerepo "github.com/aliskhannn/url-shortener/internal/repository/workspace"
+	domainsvc "github.com/aliskhannn/url-shortener/internal/service/domain"
 	linksvc "github.com/aliskhannn/url-shortener/internal/service/link"
 )
 
 // linkService defines the interface that the Handler depends on.
 type linkService interface {
 	CreateLink(ctx context.Context, strategy retry.Strategy, link model.Link) (model.Link, error)
-	GetLinkByAlias(ctx context.Context, strategy retry.Strategy, alias string) (model.Link, error)
+	GetLinkByAlias(ctx context.Context, strategy retry.Strategy, key model.LinkKey) (model.Link, error)
+	GetLink(ctx context.Context, key model.LinkKey) (model.Link, error)
+	UpdateLink(ctx context.Context, strategy retry.Strategy, key model.LinkKey, upd model.LinkUpdate) (model.Link, error)
+	DeleteLink(ctx context.Context, strategy retry.Strategy, key model.LinkKey) error
+	ClaimLinks(ctx context.Context, strategy retry.Strategy, workspaceID, ownerID uuid.UUID, aliases []string) (int, error)
+	ListLinks(ctx context.Context, filter model.LinkFilter, cursor string) (model.LinkPage, error)
 }
 
-// analyticsService defines the interface that the Handler depends on.
-type analyticsService interface {
-	SaveAnalytics(ctx context.Context, strategy retry.Strategy, event model.Analytics) (uuid.UUID, error)
+// clickQueue defines the interface that the Handler depends on.
+type clickQueue interface {
+	Enqueue(event model.Analytics) bool
+}
+
+// workspaceService defines the interface that the Handler depends on.
+type workspaceService interface {
+	GetWorkspaceBySlug(ctx context.Context, slug string) (model.Workspace, error)
+	EnsureMember(ctx context.Context, workspaceID, userID uuid.UUID, role string) error
+}
+
+// geoLocator defines the interface that the Handler depends on.
+type geoLocator interface {
+	Lookup(ip string) geoip.Location
+}
+
+// botClassifier defines the interface that the Handler depends on.
+type botClassifier interface {
+	Classify(r *http.Request) string
+}
+
+// domainService defines the interface that the Handler depends on.
+type domainService interface {
+	GetVerifiedDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error)
+	ResolveHost(ctx context.Context, host string) (model.Domain, bool, error)
 }
 
 // Handler handles HTTP requests related to link.
 type Handler struct {
-	ctx              context.Context
 	cfg              *config.Config
 	validator        *validator.Validate
 	linkService      linkService
-	analyticsService analyticsService
+	clicks           clickQueue
+	workspaceService workspaceService
+	domainService    domainService
+	geo              geoLocator
+	bots             botClassifier
+	logoClient       *http.Client // downloads QR code logos
 }
 
 // NewHandler creates a new Handler instance.
 func NewHandler(
-	ctx context.Context,
 	cfg *config.Config,
 	v *validator.Validate,
 	ls linkService,
-	as analyticsService,
+	clicks clickQueue,
+	ws workspaceService,
+	ds domainService,
+	geo geoLocator,
+	bots botClassifier,
 ) *Handler {
-	return &Handler{ctx: ctx, cfg: cfg, validator: v, linkService: ls, analyticsService: as}
+	return &Handler{
+		cfg:              cfg,
+		validator:        v,
+		linkService:      ls,
+		clicks:           clicks,
+		workspaceService: ws,
+		domainService:    ds,
+		geo:              geo,
+		bots:             bots,
+		logoClient:       qrcode.NewLogoClient(cfg.QR.LogoHosts),
+	}
 }
 
 // CreateRequest represents the expected JSON payload for creating a shortened link.
+// Expiration can be set either as an absolute timestamp or as a TTL relative to now.
 type CreateRequest struct {
-	URL   string `json:"url" validate:"required"`
-	Alias string `json:"alias"`
+	URL              string           `json:"url" validate:"required"`
+	Alias            string           `json:"alias"`
+	Domain           string           `json:"domain"`
+	ExpiresAt        *time.Time       `json:"expires_at"`
+	TTLSeconds       int64            `json:"ttl_seconds" validate:"omitempty,gt=0"`
+	RedirectCode     int              `json:"redirect_code" validate:"omitempty,oneof=301 302 307 308"`
+	RedirectMode     string           `json:"redirect_mode" validate:"omitempty,oneof=http meta js"`
+	TrackingPixels   []string         `json:"tracking_pixels" validate:"max=10,dive,url"`
+	QueryPassthrough string           `json:"query_passthrough" validate:"omitempty,oneof=off merge override"`
+	PathPassthrough  bool             `json:"path_passthrough"`
+	Rules            []RuleRequest    `json:"rules" validate:"max=20,dive"`
+	Variants         []VariantRequest `json:"variants" validate:"max=10,unique=Name,dive"`
+	PrivacyMode      string           `json:"privacy_mode" validate:"omitempty,oneof=full truncate hash"`
+}
+
+// RuleRequest represents a redirect rule in the JSON payload of a link.
+type RuleRequest struct {
+	Name     string   `json:"name" validate:"max=64"`
+	Device   []string `json:"device" validate:"dive,oneof=desktop mobile bot"`
+	OS       []string `json:"os" validate:"dive,oneof=ios android windows macos linux chromeos"`
+	Country  []string `json:"country" validate:"dive,len=2,alpha"`
+	Language []string `json:"language" validate:"dive,min=2,max=35"`
+	URL      string   `json:"url" validate:"required,url"`
+}
+
+// toRedirectRules converts rules of a request payload into redirect rules.
+func toRedirectRules(req []RuleRequest) []model.RedirectRule {
+	rules := make([]model.RedirectRule, 0, len(req))
+	for _, r := range req {
+		rules = append(rules, model.RedirectRule{
+			Name:     r.Name,
+			Device:   r.Device,
+			OS:       r.OS,
+			Country:  r.Country,
+			Language: r.Language,
+			URL:      r.URL,
+		})
+	}
+
+	return rules
+}
+
+// VariantRequest represents a weighted A/B destination in the JSON payload of a link.
+type VariantRequest struct {
+	Name   string `json:"name" validate:"required,max=64"`
+	URL    string `json:"url" validate:"required,url"`
+	Weight int    `json:"weight" validate:"min=1,max=10000"`
+}
+
+// toVariants converts variants of a request payload into link variants.
+func toVariants(req []VariantRequest) []model.Variant {
+	variants := make([]model.Variant, 0, len(req))
+	for _, v := range req {
+		variants = append(variants, model.Variant{Name: v.Name, URL: v.URL, Weight: v.Weight})
+	}
+
+	return variants
+}
+
+// LinkResponse represents a link together with its public short URL.
+type LinkResponse struct {
+	model.Link
+	ShortURL string `json:"short_url"` // public URL redirecting to the link
 }
 
 // ShortenLink handles POST /shorten requests.
@@ -78,10 +196,72 @@ func (h *Handler) ShortenLink(c *ginext.Context) {
 		return
 	}
 
-	// Construct a Link model.
+	// Resolve link expiration.
+	if req.ExpiresAt != nil && req.TTLSeconds > 0 {
+		zlog.Logger.Warn().Msg("both expires_at and ttl_seconds provided")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("expires_at and ttl_seconds are mutually exclusive"))
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.TTLSeconds > 0 {
+		t := time.Now().Add(time.Duration(req.TTLSeconds) * time.Second)
+		expiresAt = &t
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		zlog.Logger.Warn().Time("expires_at", *expiresAt).Msg("expiration is in the past")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("expires_at must be in the future"))
+		return
+	}
+
+	workspaceID := middleware.Workspace(c).ID
+
+	// Links on a custom domain require the domain to be verified by the workspace.
+	domain := domainsvc.NormalizeHost(req.Domain)
+	if domain != "" {
+		if _, err := h.domainService.GetVerifiedDomain(c.Request.Context(), workspaceID, domain); err != nil {
+			switch {
+			case errors.Is(err, domainrepo.ErrDomainNotFound):
+				zlog.Logger.Warn().Str("domain", domain).Msg("domain not found")
+				respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("domain not found"))
+			case errors.Is(err, domainsvc.ErrNotVerified):
+				zlog.Logger.Warn().Str("domain", domain).Msg("domain not verified")
+				respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("domain not verified"))
+			default:
+				zlog.Logger.Error().Err(err).Str("domain", domain).Msg("failed to get domain")
+				respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+			}
+			return
+		}
+	}
+
+	// Construct a Link model, redirecting with 302 Found unless configured otherwise.
 	link := model.Link{
-		URL:   req.URL,
-		Alias: req.Alias,
+		URL:              req.URL,
+		Alias:            req.Alias,
+		WorkspaceID:      workspaceID,
+		Domain:           domain,
+		OwnerID:          middleware.OwnerID(c),
+		RedirectCode:     http.StatusFound,
+		RedirectMode:     model.RedirectModeHTTP,
+		TrackingPixels:   req.TrackingPixels,
+		QueryPassthrough: model.QueryPassthroughOff,
+		PathPassthrough:  req.PathPassthrough,
+		Rules:            toRedirectRules(req.Rules),
+		Variants:         toVariants(req.Variants),
+		PrivacyMode:      req.PrivacyMode,
+		ExpiresAt:        expiresAt,
+	}
+
+	if req.RedirectCode != 0 {
+		link.RedirectCode = req.RedirectCode
+	}
+	if req.RedirectMode != "" {
+		link.RedirectMode = req.RedirectMode
+	}
+	if req.QueryPassthrough != "" {
+		link.QueryPassthrough = req.QueryPassthrough
 	}
 
 	// Create a shorted link using the service layer.
@@ -94,17 +274,321 @@ func (h *Handler) ShortenLink(c *ginext.Context) {
 			return
 		}
 
+		// Handle alias colliding with application paths.
+		if errors.Is(err, linksvc.ErrAliasReserved) {
+			zlog.Logger.Warn().Str("alias", link.Alias).Msg("alias reserved")
+			respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("alias is reserved"))
+			return
+		}
+
 		// Internal errors.
 		zlog.Logger.Error().Err(err).Str("alias", link.Alias).Msg("failed to shorten link")
 		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 		return
 	}
 
-	respond.Created(c.Writer, res)
+	respond.Created(c.Writer, h.linkResponse(c, res))
+}
+
+// UpdateRequest represents the expected JSON payload for updating a shortened link.
+// Omitted fields are left unchanged.
+type UpdateRequest struct {
+	URL              *string           `json:"url" validate:"omitempty,min=1"`
+	Enabled          *bool             `json:"enabled"`
+	RedirectCode     *int              `json:"redirect_code" validate:"omitempty,oneof=301 302 307 308"`
+	RedirectMode     *string           `json:"redirect_mode" validate:"omitempty,oneof=http meta js"`
+	TrackingPixels   *[]string         `json:"tracking_pixels" validate:"omitempty,max=10,dive,url"`
+	QueryPassthrough *string           `json:"query_passthrough" validate:"omitempty,oneof=off merge override"`
+	PathPassthrough  *bool             `json:"path_passthrough"`
+	Rules            *[]RuleRequest    `json:"rules" validate:"omitempty,max=20,dive"`
+	Variants         *[]VariantRequest `json:"variants" validate:"omitempty,max=10,unique=Name,dive"`
+	PrivacyMode      *string           `json:"privacy_mode" validate:"omitempty,oneof='' full truncate hash"`
+}
+
+// Page size limits for listing links.
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListLinks handles GET /links requests.
+// It returns a page of links filtered and sorted by query parameters:
+// domain, host, alias_prefix, created_from, created_to, q, sort (created_at|clicks),
+// order (asc|desc), limit and cursor.
+func (h *Handler) ListLinks(c *ginext.Context) {
+	filter, err := parseLinkFilter(c)
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("invalid list links parameters")
+		respond.Fail(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := h.linkService.ListLinks(c.Request.Context(), filter, c.Query("cursor"))
+	if err != nil {
+		if errors.Is(err, linksvc.ErrInvalidCursor) {
+			zlog.Logger.Warn().Err(err).Msg("invalid cursor")
+			respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid cursor"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Msg("failed to list links")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, page)
 }
 
-// RedirectLink handles GET /s/:alias requests.
+// parseLinkFilter builds a LinkFilter from the query parameters of a list links request.
+func parseLinkFilter(c *ginext.Context) (model.LinkFilter, error) {
+	filter := model.LinkFilter{
+		WorkspaceID: middleware.Workspace(c).ID,
+		OwnerID:     middleware.LinkOwner(c),
+		Host:        c.Query("host"),
+		AliasPrefix: c.Query("alias_prefix"),
+		Query:       c.Query("q"),
+		Sort:        c.DefaultQuery("sort", model.LinkSortCreatedAt),
+		Limit:       defaultListLimit,
+	}
+
+	if v, ok := c.GetQuery("domain"); ok {
+		domain := domainsvc.NormalizeHost(v)
+		filter.Domain = &domain
+	}
+
+	if filter.Sort != model.LinkSortCreatedAt && filter.Sort != model.LinkSortClicks {
+		return filter, fmt.Errorf("sort must be one of: created_at, clicks")
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+		filter.Desc = true
+	case "asc":
+		filter.Desc = false
+	default:
+		return filter, fmt.Errorf("order must be one of: asc, desc")
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return filter, fmt.Errorf("limit must be an integer between 1 and %d", maxListLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := c.Query("created_from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("created_from must be an RFC 3339 timestamp")
+		}
+		filter.CreatedFrom = &t
+	}
+
+	if v := c.Query("created_to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("created_to must be an RFC 3339 timestamp")
+		}
+		filter.CreatedTo = &t
+	}
+
+	return filter, nil
+}
+
+// linkKey identifies the link addressed by a management request: the alias path
+// parameter within the request workspace and the optional domain query parameter,
+// restricted to links of the member in owner-scoped workspaces.
+func linkKey(c *ginext.Context) model.LinkKey {
+	return model.LinkKey{
+		WorkspaceID: middleware.Workspace(c).ID,
+		Domain:      domainsvc.NormalizeHost(c.Query("domain")),
+		Alias:       c.Param("alias"),
+		OwnerID:     middleware.LinkOwner(c),
+	}
+}
+
+// GetLink handles GET /links/:alias requests.
+// It returns the link regardless of its enabled or expiration state.
+func (h *Handler) GetLink(c *ginext.Context) {
+	alias := c.Param("alias")
+
+	link, err := h.linkService.GetLink(c.Request.Context(), linkKey(c))
+	if err != nil {
+		if errors.Is(err, linkrepo.ErrAliasNotFound) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("alias not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to get link")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, h.linkResponse(c, link))
+}
+
+// UpdateLink handles PATCH /links/:alias requests.
+// It changes the destination URL and/or enabled state of a link.
+func (h *Handler) UpdateLink(c *ginext.Context) {
+	alias := c.Param("alias")
+
+	var req UpdateRequest
+
+	// Decode JSON request body into UpdateRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	if req.URL == nil && req.Enabled == nil && req.RedirectCode == nil && req.RedirectMode == nil &&
+		req.TrackingPixels == nil && req.QueryPassthrough == nil && req.PathPassthrough == nil &&
+		req.Rules == nil && req.Variants == nil && req.PrivacyMode == nil {
+		zlog.Logger.Warn().Str("alias", alias).Msg("empty update request")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("nothing to update"))
+		return
+	}
+
+	upd := model.LinkUpdate{
+		URL:              req.URL,
+		Enabled:          req.Enabled,
+		RedirectCode:     req.RedirectCode,
+		RedirectMode:     req.RedirectMode,
+		TrackingPixels:   req.TrackingPixels,
+		QueryPassthrough: req.QueryPassthrough,
+		PathPassthrough:  req.PathPassthrough,
+		PrivacyMode:      req.PrivacyMode,
+	}
+
+	if req.Rules != nil {
+		rules := toRedirectRules(*req.Rules)
+		upd.Rules = &rules
+	}
+	if req.Variants != nil {
+		variants := toVariants(*req.Variants)
+		upd.Variants = &variants
+	}
+
+	link, err := h.linkService.UpdateLink(c.Request.Context(), h.cfg.Retry, linkKey(c), upd)
+	if err != nil {
+		if errors.Is(err, linkrepo.ErrAliasNotFound) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("alias not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to update link")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, h.linkResponse(c, link))
+}
+
+// DeleteLink handles DELETE /links/:alias requests.
+// It deletes a link together with its analytics.
+func (h *Handler) DeleteLink(c *ginext.Context) {
+	alias := c.Param("alias")
+
+	err := h.linkService.DeleteLink(c.Request.Context(), h.cfg.Retry, linkKey(c))
+	if err != nil {
+		if errors.Is(err, linkrepo.ErrAliasNotFound) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("alias not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to delete link")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.NoContent(c.Writer)
+}
+
+// ClaimRequest represents the expected JSON payload for claiming links without an owner.
+type ClaimRequest struct {
+	OwnerID   uuid.UUID `json:"owner_id"`                                  // new owner of the links
+	Workspace string    `json:"workspace"`                                 // workspace slug, the default workspace if empty
+	Aliases   []string  `json:"aliases" validate:"max=1000,dive,required"` // aliases to claim, all links without an owner if empty
+}
+
+// ClaimResponse represents the result of claiming links.
+type ClaimResponse struct {
+	Claimed int `json:"claimed"` // number of claimed links
+}
+
+// ClaimLinks handles POST /admin/links/claim requests.
+// It assigns links of a workspace that have no owner, e.g. links created before API keys
+// existed, to an owner and makes the owner an editor of the workspace unless a member.
+func (h *Handler) ClaimLinks(c *ginext.Context) {
+	var req ClaimRequest
+
+	// Decode JSON request body into ClaimRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	if req.OwnerID == uuid.Nil {
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("missing owner_id"))
+		return
+	}
+
+	slug := req.Workspace
+	if slug == "" {
+		slug = model.DefaultWorkspaceSlug
+	}
+
+	ws, err := h.workspaceService.GetWorkspaceBySlug(c.Request.Context(), slug)
+	if err != nil {
+		if errors.Is(err, workspacerepo.ErrWorkspaceNotFound) {
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("workspace not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("workspace", slug).Msg("failed to get workspace")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	if err := h.workspaceService.EnsureMember(c.Request.Context(), ws.ID, req.OwnerID, model.RoleEditor); err != nil {
+		zlog.Logger.Error().Err(err).Str("workspace", slug).Msg("failed to add workspace member")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	n, err := h.linkService.ClaimLinks(c.Request.Context(), h.cfg.Retry, ws.ID, req.OwnerID, req.Aliases)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("workspace", slug).Msg("failed to claim links")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	zlog.Logger.Info().Int("count", n).Str("workspace", slug).Msg("claimed links")
+	respond.JSON(c.Writer, http.StatusOK, ClaimResponse{Claimed: n})
+}
+
+// RedirectLink handles GET /s/:alias, GET /w/:workspace/:alias and GET /:alias requests,
+// optionally followed by a path forwarded to the destination of path passthrough links.
 // It resolves a short alias to the original URL, saves analytics, and redirects the user.
+// Requests to a verified custom domain resolve aliases of that domain, /s/:alias and
+// /:alias on any other host resolve aliases of the default workspace.
 func (h *Handler) RedirectLink(c *ginext.Context) {
 	alias := c.Param("alias")
 
@@ -114,8 +598,21 @@ func (h *Handler) RedirectLink(c *ginext.Context) {
 		return
 	}
 
+	key, err := h.resolveLinkKey(c)
+	if err != nil {
+		if errors.Is(err, workspacerepo.ErrWorkspaceNotFound) {
+			zlog.Logger.Warn().Str("workspace", c.Param("workspace")).Msg("workspace not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("host", c.Request.Host).Msg("failed to resolve link namespace")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
 	// Lookup the link in the service (cache → DB).
-	link, err := h.linkService.GetLinkByAlias(c.Request.Context(), h.cfg.Retry, alias)
+	link, err := h.linkService.GetLinkByAlias(c.Request.Context(), h.cfg.Retry, key)
 	if err != nil {
 		// Handle case when alias does not exist.
 		if errors.Is(err, linkrepo.ErrAliasNotFound) {
@@ -124,38 +621,158 @@ func (h *Handler) RedirectLink(c *ginext.Context) {
 			return
 		}
 
+		// Handle case when link has expired.
+		if errors.Is(err, linksvc.ErrLinkExpired) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("link expired")
+			respond.Fail(c.Writer, http.StatusGone, fmt.Errorf("link expired"))
+			return
+		}
+
+		// Handle case when link has been disabled.
+		if errors.Is(err, linksvc.ErrLinkDisabled) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("link disabled")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("link disabled"))
+			return
+		}
+
 		// Internal errors.
 		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to get link")
 		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 		return
 	}
 
+	// A path after the alias is only served by links forwarding it to the destination.
+	rest := c.Param("rest")
+	if !link.PathPassthrough && strings.Trim(rest, "/") != "" {
+		zlog.Logger.Warn().Str("alias", alias).Str("path", rest).Msg("path passthrough disabled")
+		respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+		return
+	}
+
+	// Dot segments would climb above the path of the destination.
+	if hasDotSegment(rest) {
+		zlog.Logger.Warn().Str("alias", alias).Str("path", rest).Msg("dot segment in path")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid path"))
+		return
+	}
+
+	// The src=qr marker of QR code scans is recorded in analytics instead of being forwarded.
+	query := c.Request.URL.Query()
+	source := ""
+	if query.Get(sourceParam) == model.SourceQR {
+		source = model.SourceQR
+		query.Del(sourceParam)
+	}
+
+	// Evaluate redirect rules, falling back to the A/B variant or the link's URL.
+	cl := parseClient(c.Request)
+	subject := model.RuleSubject{Device: cl.Device, OSFamily: cl.OSFamily, Language: cl.Language}
+	if link.RulesNeedCountry() {
+		subject.Country = h.geo.Lookup(cl.IP).Country
+	}
+
+	destination, rule, variant := link.URL, "", ""
+	if i := link.MatchRule(subject); i >= 0 {
+		destination, rule = link.Rules[i].URL, link.Rules[i].Label(i)
+	} else if i := assignVariant(c, link, cl); i >= 0 {
+		destination, variant = link.Variants[i].URL, link.Variants[i].Name
+	}
+
+	target, err := destinationURL(link, destination, rest, query)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to build destination url")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
 	// Build an analytics event from the request.
-	event := h.buildAnalytics(link.Alias, c.Request)
-	zlog.Logger.Info().Interface("event", event).Msg("got event")
+	event := h.buildAnalytics(link, cl, c.Request)
+	event.Source = source
+	event.Rule = rule
+	event.Variant = variant
+
+	// Queue the event to be enriched, anonymized and saved in the background.
+	if !h.clicks.Enqueue(event) {
+		zlog.Logger.Warn().Str("alias", alias).Msg("click event dropped")
+	}
 
-	// Save analytics asynchronously.
-	go h.saveAnalyticsAsync(event)
+	h.redirect(c, link, target)
+}
 
-	http.Redirect(c.Writer, c.Request, link.URL, http.StatusFound)
+// linkResponse wraps a link of the request workspace into a LinkResponse.
+func (h *Handler) linkResponse(c *ginext.Context, link model.Link) LinkResponse {
+	return LinkResponse{Link: link, ShortURL: h.shortURL(link, middleware.Workspace(c).Slug)}
 }
 
-// saveAnalyticsAsync saves analytics data asynchronously using the global context.
-func (h *Handler) saveAnalyticsAsync(event model.Analytics) {
-	id, err := h.analyticsService.SaveAnalytics(h.ctx, h.cfg.Retry, event)
+// shortURL builds the public short URL of the link. Links on a custom domain use
+// that domain with the scheme of the configured base URL, links of workspaces other
+// than the default one are served under /api/w/:workspace.
+func (h *Handler) shortURL(link model.Link, workspaceSlug string) string {
+	base := strings.TrimSuffix(h.cfg.Server.BaseURL, "/")
+	if link.Domain != "" {
+		scheme := "https"
+		if u, err := url.Parse(base); err == nil && u.Scheme != "" {
+			scheme = u.Scheme
+		}
+		base = scheme + "://" + link.Domain
+	}
+
+	path := "/" + url.PathEscape(link.Alias)
+	switch {
+	case link.Domain == "" && workspaceSlug != model.DefaultWorkspaceSlug:
+		path = "/api/w/" + url.PathEscape(workspaceSlug) + path
+	case !h.cfg.Server.RootRedirects:
+		path = "/api/s" + path
+	}
+
+	return base + path
+}
+
+// resolveLinkKey determines the workspace and domain the requested alias belongs to:
+// the workspace path parameter if present, otherwise the custom domain of the Host
+// header, otherwise the default workspace.
+func (h *Handler) resolveLinkKey(c *ginext.Context) (model.LinkKey, error) {
+	ctx := c.Request.Context()
+	key := model.LinkKey{Alias: c.Param("alias")}
+
+	slug := c.Param("workspace")
+	if slug == "" {
+		d, ok, err := h.domainService.ResolveHost(ctx, c.Request.Host)
+		if err != nil {
+			return key, fmt.Errorf("resolve host: %w", err)
+		}
+
+		if ok {
+			key.WorkspaceID = d.WorkspaceID
+			key.Domain = d.Hostname
+			return key, nil
+		}
+
+		slug = model.DefaultWorkspaceSlug
+	}
+
+	ws, err := h.workspaceService.GetWorkspaceBySlug(ctx, slug)
 	if err != nil {
-		zlog.Logger.Error().
-			Err(err).
-			Str("alias", event.Alias).
-			Msg("failed to save analytics")
-		return
+		return key, fmt.Errorf("get workspace: %w", err)
 	}
 
-	zlog.Logger.Info().Str("id", id.String()).Msg("saved analytics")
+	key.WorkspaceID = ws.ID
+	return key, nil
+}
+
+// client holds attributes of the visitor parsed from a redirect request.
+type client struct {
+	UserAgent string // raw user agent string
+	Device    string // device type: desktop, mobile or bot
+	OS        string // operating system as reported by the user agent
+	OSFamily  string // normalized OS family, e.g. model.OSFamilyIOS
+	Browser   string // browser name
+	IP        string // client ip address
+	Language  string // preferred locale
 }
 
-// buildAnalytics constructs an Analytics model from the HTTP request.
-func (h *Handler) buildAnalytics(alias string, r *http.Request) model.Analytics {
+// parseClient parses the visitor attributes from the HTTP request.
+func parseClient(r *http.Request) client {
 	ua := user_agent.New(r.UserAgent())
 
 	// Detect browser name.
@@ -169,18 +786,130 @@ func (h *Handler) buildAnalytics(alias string, r *http.Request) model.Analytics
 		device = "bot"
 	}
 
-	// Extract client IP (RemoteAddr may include port).
+	// Extract client IP resolved by middleware.ClientIPMiddleware (RemoteAddr may include port).
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		ip = r.RemoteAddr
 	}
 
-	return model.Analytics{
-		Alias:     alias,
+	return client{
 		UserAgent: r.UserAgent(),
 		Device:    device,
 		OS:        ua.OS(),
+		OSFamily:  osFamily(ua),
 		Browser:   browserName,
 		IP:        ip,
+		Language:  truncate(primaryLanguage(r.Header.Get("Accept-Language")), maxLanguageLength),
+	}
+}
+
+// osFamily returns the normalized OS family of the user agent, or an empty string if unknown.
+func osFamily(ua *user_agent.UserAgent) string {
+	os := strings.ToLower(ua.OS())
+
+	switch {
+	case ua.Platform() == "iPhone" || ua.Platform() == "iPad" || ua.Platform() == "iPod":
+		return model.OSFamilyIOS
+	case strings.Contains(os, "android"):
+		return model.OSFamilyAndroid
+	case strings.Contains(os, "windows"):
+		return model.OSFamilyWindows
+	case strings.Contains(os, "mac os x"):
+		return model.OSFamilyMacOS
+	case strings.Contains(os, "cros"):
+		return model.OSFamilyChromeOS
+	case strings.Contains(os, "linux"):
+		return model.OSFamilyLinux
+	default:
+		return ""
+	}
+}
+
+// buildAnalytics constructs an Analytics model from the visitor and the HTTP request.
+func (h *Handler) buildAnalytics(link model.Link, cl client, r *http.Request) model.Analytics {
+	query := r.URL.Query()
+	botReason := h.bots.Classify(r)
+
+	return model.Analytics{
+		ID:             uuid.New(),
+		LinkID:         link.ID,
+		WorkspaceID:    link.WorkspaceID,
+		Alias:          link.Alias,
+		UserAgent:      cl.UserAgent,
+		Device:         cl.Device,
+		IsBot:          botReason != "",
+		BotReason:      botReason,
+		OS:             cl.OS,
+		OSFamily:       cl.OSFamily,
+		Browser:        cl.Browser,
+		IP:             cl.IP,
+		Referrer:       truncate(r.Referer(), maxReferrerLength),
+		ReferrerDomain: truncate(referrerDomain(r.Referer()), maxHostLength),
+		UTMSource:      truncate(query.Get("utm_source"), maxUTMLength),
+		UTMMedium:      truncate(query.Get("utm_medium"), maxUTMLength),
+		UTMCampaign:    truncate(query.Get("utm_campaign"), maxUTMLength),
+		UTMTerm:        truncate(query.Get("utm_term"), maxUTMLength),
+		UTMContent:     truncate(query.Get("utm_content"), maxUTMLength),
+		Language:       cl.Language,
+		CreatedAt:      time.Now().UTC(),
+		PrivacyMode:    h.privacyMode(link),
+		DoNotTrack:     h.cfg.Privacy.HonorDoNotTrack && doNotTrack(r),
+	}
+}
+
+// privacyMode returns the privacy mode of clicks on the link, falling back to the global one.
+func (h *Handler) privacyMode(link model.Link) string {
+	if link.PrivacyMode != "" {
+		return link.PrivacyMode
+	}
+
+	return h.cfg.Privacy.Mode
+}
+
+// doNotTrack reports whether the visitor opted out of tracking with the DNT or Sec-GPC header.
+func doNotTrack(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
+
+// Maximal stored lengths of request attributes.
+const (
+	maxReferrerLength = 2048
+	maxHostLength     = 253
+	maxUTMLength      = 255
+	maxLanguageLength = 35
+)
+
+// referrerDomain returns the lowercased host of the referrer URL without the www. prefix,
+// or an empty string if the referrer is missing or not an absolute URL.
+func referrerDomain(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
 	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// primaryLanguage returns the locale with the highest quality in the Accept-Language
+// header, or an empty string if there is none.
+func primaryLanguage(header string) string {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 || tags[0] == language.Und {
+		return ""
+	}
+
+	return tags[0].String()
+}
+
+// truncate cuts s to at most n bytes without splitting UTF-8 characters.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
 }
@@ -0,0 +1,155 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/api/respond"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	"github.com/aliskhannn/url-shortener/internal/qrcode"
+	linkrepo "github.com/aliskhannn/url-shortener/internal/repository/link"
+)
+
+// Defaults of QR code rendering options.
+const (
+	defaultQRSize   = 256
+	defaultQRMargin = 4
+	defaultQRLevel  = "M"
+	logoQRLevel     = "H" // used with a logo unless the level is set explicitly
+)
+
+// QRCode handles GET /links/:alias/qr requests.
+// It renders a QR code of the link's short URL marked with src=qr, so that scans are
+// distinguishable in analytics. Supported query parameters: format (png|svg), size (pixels),
+// level (L|M|Q|H), margin (modules), fg and bg (hex colors) and logo (image URL).
+func (h *Handler) QRCode(c *ginext.Context) {
+	alias := c.Param("alias")
+
+	link, err := h.linkService.GetLink(c.Request.Context(), linkKey(c))
+	if err != nil {
+		if errors.Is(err, linkrepo.ErrAliasNotFound) {
+			zlog.Logger.Warn().Str("alias", alias).Msg("alias not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("alias not found"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to get link")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	format := c.DefaultQuery("format", "png")
+	if format != "png" && format != "svg" {
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("format must be one of: png, svg"))
+		return
+	}
+
+	opts, err := h.parseQROptions(c)
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Str("alias", alias).Msg("invalid qr code parameters")
+		respond.Fail(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := url.Parse(h.shortURL(link, middleware.Workspace(c).Slug))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to parse short url")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+	u.RawQuery = url.Values{sourceParam: {model.SourceQR}}.Encode()
+
+	var (
+		body        []byte
+		contentType string
+	)
+
+	if format == "svg" {
+		body, err = qrcode.SVG(u.String(), opts)
+		contentType = "image/svg+xml"
+	} else {
+		body, err = qrcode.PNG(u.String(), opts)
+		contentType = "image/png"
+	}
+
+	if err != nil {
+		if errors.Is(err, qrcode.ErrTooSmall) {
+			respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("size too small for the qr code"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to render qr code")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// parseQROptions builds QR code rendering options from the query parameters.
+func (h *Handler) parseQROptions(c *ginext.Context) (qrcode.Options, error) {
+	opts := qrcode.Options{
+		Size:       defaultQRSize,
+		Margin:     defaultQRMargin,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+
+	if v := c.Query("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < qrcode.MinSize || size > qrcode.MaxSize {
+			return opts, fmt.Errorf("size must be an integer between %d and %d", qrcode.MinSize, qrcode.MaxSize)
+		}
+		opts.Size = size
+	}
+
+	if v := c.Query("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > qrcode.MaxMargin {
+			return opts, fmt.Errorf("margin must be an integer between 0 and %d", qrcode.MaxMargin)
+		}
+		opts.Margin = margin
+	}
+
+	var err error
+	if v := c.Query("fg"); v != "" {
+		if opts.Foreground, err = qrcode.ParseColor(v); err != nil {
+			return opts, fmt.Errorf("fg must be a hex color")
+		}
+	}
+	if v := c.Query("bg"); v != "" {
+		if opts.Background, err = qrcode.ParseColor(v); err != nil {
+			return opts, fmt.Errorf("bg must be a hex color")
+		}
+	}
+
+	level := defaultQRLevel
+	if logo := c.Query("logo"); logo != "" {
+		u, err := url.Parse(logo)
+		if err != nil || !qrcode.AllowedLogoURL(u, h.cfg.QR.LogoHosts) {
+			return opts, fmt.Errorf("logo must be an http(s) url on an allowed host")
+		}
+
+		if opts.Logo, err = qrcode.FetchLogo(c.Request.Context(), h.logoClient, u.String()); err != nil {
+			zlog.Logger.Warn().Err(err).Str("logo", logo).Msg("failed to fetch logo")
+			return opts, fmt.Errorf("logo must be a png, jpeg or gif image")
+		}
+
+		// A logo hides modules in the center, so maximal error correction is used by default.
+		level = logoQRLevel
+	}
+
+	if opts.Level, err = qrcode.ParseLevel(c.DefaultQuery("level", level)); err != nil {
+		return opts, fmt.Errorf("level must be one of: L, M, Q, H")
+	}
+
+	return opts, nil
+}
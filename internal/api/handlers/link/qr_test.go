@@ -0,0 +1,115 @@
+package link
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wb-go/wbf/ginext"
+	"rsc.io/qr"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/qrcode"
+)
+
+func TestParseQROptions(t *testing.T) {
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logo.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(logo.Bytes())
+	}))
+	defer srv.Close()
+
+	hosts := []string{"127.0.0.1"}
+	h := &Handler{
+		cfg:        &config.Config{QR: config.QR{LogoHosts: hosts}},
+		logoClient: qrcode.NewLogoClient(hosts),
+	}
+
+	black := color.RGBA{A: 0xff}
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	tests := []struct {
+		name     string
+		query    string
+		want     qrcode.Options
+		wantLogo bool
+		wantErr  bool
+	}{
+		{
+			name: "defaults",
+			want: qrcode.Options{Size: defaultQRSize, Level: qr.M, Margin: defaultQRMargin, Foreground: black, Background: white},
+		},
+		{
+			name:  "all options",
+			query: "size=512&margin=0&level=q&fg=%23f00&bg=00ff00",
+			want: qrcode.Options{
+				Size:       512,
+				Level:      qr.Q,
+				Margin:     0,
+				Foreground: color.RGBA{R: 0xff, A: 0xff},
+				Background: color.RGBA{G: 0xff, A: 0xff},
+			},
+		},
+		{
+			name:     "logo raises level",
+			query:    "logo=" + srv.URL + "/logo.png",
+			want:     qrcode.Options{Size: defaultQRSize, Level: qr.H, Margin: defaultQRMargin, Foreground: black, Background: white},
+			wantLogo: true,
+		},
+		{
+			name:     "logo with explicit level",
+			query:    "level=L&logo=" + srv.URL + "/logo.png",
+			want:     qrcode.Options{Size: defaultQRSize, Level: qr.L, Margin: defaultQRMargin, Foreground: black, Background: white},
+			wantLogo: true,
+		},
+		{name: "size too small", query: "size=63", wantErr: true},
+		{name: "size too large", query: "size=2049", wantErr: true},
+		{name: "size not a number", query: "size=big", wantErr: true},
+		{name: "negative margin", query: "margin=-1", wantErr: true},
+		{name: "margin too large", query: "margin=17", wantErr: true},
+		{name: "invalid level", query: "level=X", wantErr: true},
+		{name: "invalid fg", query: "fg=red", wantErr: true},
+		{name: "invalid bg", query: "bg=12345", wantErr: true},
+		{name: "logo on disallowed host", query: "logo=https://example.com/logo.png", wantErr: true},
+		{name: "logo not http", query: "logo=file:///etc/passwd", wantErr: true},
+		{name: "logo not found", query: "logo=" + srv.URL + "/missing.png", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ginext.Context{Request: httptest.NewRequest(http.MethodGet, "/links/abc/qr?"+tt.query, nil)}
+
+			got, err := h.parseQROptions(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseQROptions() = %+v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseQROptions() error = %v", err)
+			}
+			if (got.Logo != nil) != tt.wantLogo {
+				t.Errorf("parseQROptions() logo = %v, want logo %t", got.Logo, tt.wantLogo)
+			}
+
+			got.Logo = nil
+			if got != tt.want {
+				t.Errorf("parseQROptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package link
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// sourceParam is the query parameter marking the traffic source of a visit, e.g. src=qr.
+const sourceParam = "src"
+
+// pixelTimeoutMillis bounds how long the JS redirect page waits for tracking pixels.
+const pixelTimeoutMillis = 1500
+
+// metaRedirectPage redirects with a meta refresh, falling back to a plain link.
+var metaRedirectPage = template.Must(template.New("meta").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+<title>Redirecting…</title>
+</head>
+<body>
+<p>Redirecting to <a href="{{.URL}}">{{.URL}}</a>…</p>
+</body>
+</html>
+`))
+
+// jsRedirectPage fires tracking pixels and redirects once they load or time out.
+var jsRedirectPage = template.Must(template.New("js").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Redirecting…</title>
+<noscript><meta http-equiv="refresh" content="0; url={{.URL}}"></noscript>
+</head>
+<body>
+<p>Redirecting to <a href="{{.URL}}">{{.URL}}</a>…</p>
+<script>
+(function () {
+  var target = {{.URL}};
+  var pixels = {{.Pixels}} || [];
+  var pending = pixels.length;
+  var done = false;
+  function leave() {
+    if (done) { return; }
+    done = true;
+    window.location.replace(target);
+  }
+  if (pending === 0) { leave(); return; }
+  pixels.forEach(function (src) {
+    var img = new Image();
+    img.onload = img.onerror = function () { if (--pending === 0) { leave(); } };
+    img.src = src;
+  });
+  setTimeout(leave, {{.Timeout}});
+})();
+</script>
+</body>
+</html>
+`))
+
+// redirectPageData holds values rendered into redirect pages.
+type redirectPageData struct {
+	URL     string
+	Pixels  []string
+	Timeout int
+}
+
+// redirect sends the visitor to the target URL according to the link's redirect mode.
+func (h *Handler) redirect(c *ginext.Context, link model.Link, target string) {
+	var page *template.Template
+
+	switch link.RedirectMode {
+	case model.RedirectModeMeta:
+		page = metaRedirectPage
+	case model.RedirectModeJS:
+		page = jsRedirectPage
+	}
+
+	// Pages navigate from our origin, so only web destinations are rendered into them.
+	if page == nil || !isWebURL(target) {
+		http.Redirect(c.Writer, c.Request, target, link.RedirectStatus())
+		return
+	}
+
+	// Pages are served on every visit so that pixels fire and clicks are recorded.
+	c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	data := redirectPageData{URL: target, Pixels: link.TrackingPixels, Timeout: pixelTimeoutMillis}
+	if err := page.Execute(c.Writer, data); err != nil {
+		zlog.Logger.Error().Err(err).Str("alias", link.Alias).Msg("failed to render redirect page")
+	}
+}
+
+// destinationURL builds the redirect target from the link's destination for a request with
+// the given path following the alias and query parameters, according to the link's
+// passthrough options.
+func destinationURL(link model.Link, destination, rest string, query url.Values) (string, error) {
+	passQuery := len(query) > 0 &&
+		(link.QueryPassthrough == model.QueryPassthroughMerge || link.QueryPassthrough == model.QueryPassthroughOverride)
+	passPath := link.PathPassthrough && strings.Trim(rest, "/") != ""
+
+	if !passQuery && !passPath {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("parse destination url: %w", err)
+	}
+
+	if passPath {
+		// The path is appended as is, callers reject dot segments climbing above the destination path.
+		segments := strings.Split(strings.Trim(rest, "/"), "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+
+		escaped := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.Join(segments, "/")
+		if strings.HasSuffix(rest, "/") {
+			escaped += "/"
+		}
+
+		if u.Path, err = url.PathUnescape(escaped); err != nil {
+			return "", fmt.Errorf("unescape destination path: %w", err)
+		}
+		u.RawPath = escaped
+	}
+
+	if passQuery {
+		u.RawQuery = passthroughQuery(u.RawQuery, query, link.QueryPassthrough == model.QueryPassthroughOverride)
+	}
+
+	return u.String(), nil
+}
+
+// passthroughQuery appends the request query parameters to the raw query of the destination,
+// keeping the destination's parameters in their original order and encoding. Parameters
+// present in both are taken from the destination, unless override is set.
+func passthroughQuery(raw string, query url.Values, override bool) string {
+	dest, _ := url.ParseQuery(raw) // malformed pairs are kept as is and never match
+
+	var pairs []string
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil && override && query.Has(k) {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+
+	extra := url.Values{}
+	for k, v := range query {
+		if !override && dest.Has(k) {
+			continue
+		}
+		extra[k] = v
+	}
+	if encoded := extra.Encode(); encoded != "" {
+		pairs = append(pairs, encoded)
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// hasDotSegment reports whether the path has a "." or ".." segment.
+func hasDotSegment(path string) bool {
+	for _, s := range strings.Split(path, "/") {
+		if s == "." || s == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWebURL reports whether target is an absolute http or https URL.
+func isWebURL(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
@@ -0,0 +1,217 @@
+package link
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+func TestDestinationURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		path        bool
+		destination string
+		rest        string
+		params      url.Values
+		want        string
+	}{
+		{
+			name:        "passthrough off",
+			query:       model.QueryPassthroughOff,
+			destination: "https://example.com/p?a=1",
+			rest:        "x/y",
+			params:      url.Values{"b": {"2"}},
+			want:        "https://example.com/p?a=1",
+		},
+		{
+			name:        "merge keeps destination values",
+			query:       model.QueryPassthroughMerge,
+			destination: "https://example.com/p?b=2&a=1",
+			params:      url.Values{"a": {"9"}, "c": {"3"}},
+			want:        "https://example.com/p?b=2&a=1&c=3",
+		},
+		{
+			name:        "override replaces destination values",
+			query:       model.QueryPassthroughOverride,
+			destination: "https://example.com/p?b=2&a=1",
+			params:      url.Values{"a": {"9"}, "c": {"3"}},
+			want:        "https://example.com/p?b=2&a=9&c=3",
+		},
+		{
+			name:        "merge without request query",
+			query:       model.QueryPassthroughMerge,
+			destination: "https://example.com/p?a=1#top",
+			want:        "https://example.com/p?a=1#top",
+		},
+		{
+			name:        "path appended",
+			path:        true,
+			destination: "https://example.com/docs/",
+			rest:        "guide/intro",
+			want:        "https://example.com/docs/guide/intro",
+		},
+		{
+			name:        "path appended to bare host",
+			path:        true,
+			destination: "https://example.com",
+			rest:        "guide",
+			want:        "https://example.com/guide",
+		},
+		{
+			name:        "trailing slash kept",
+			path:        true,
+			destination: "https://example.com/docs",
+			rest:        "guide/",
+			want:        "https://example.com/docs/guide/",
+		},
+		{
+			name:        "empty rest leaves destination",
+			path:        true,
+			destination: "https://example.com/docs?a=1",
+			rest:        "/",
+			want:        "https://example.com/docs?a=1",
+		},
+		{
+			name:        "segments escaped",
+			path:        true,
+			destination: "https://example.com/docs",
+			rest:        "a b/c?d#e",
+			want:        "https://example.com/docs/a%20b/c%3Fd%23e",
+		},
+		{
+			name:        "escaped destination path kept",
+			path:        true,
+			destination: "https://example.com/a%2Fb",
+			rest:        "c",
+			want:        "https://example.com/a%2Fb/c",
+		},
+		{
+			name:        "path and query",
+			query:       model.QueryPassthroughMerge,
+			path:        true,
+			destination: "https://example.com/d?x=1",
+			rest:        "y",
+			params:      url.Values{"z": {"2"}},
+			want:        "https://example.com/d/y?x=1&z=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := model.Link{QueryPassthrough: tt.query, PathPassthrough: tt.path}
+			got, err := destinationURL(link, tt.destination, tt.rest, tt.params)
+			if err != nil {
+				t.Fatalf("destinationURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("destinationURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassthroughQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		query    url.Values
+		override bool
+		want     string
+	}{
+		{
+			name:  "empty destination",
+			query: url.Values{"a": {"1"}},
+			want:  "a=1",
+		},
+		{
+			name:  "merge keeps destination value",
+			raw:   "b=2&a=1",
+			query: url.Values{"a": {"9"}},
+			want:  "b=2&a=1",
+		},
+		{
+			name:     "override replaces in place of destination value",
+			raw:      "b=2&a=1",
+			query:    url.Values{"a": {"9"}},
+			override: true,
+			want:     "b=2&a=9",
+		},
+		{
+			name:  "destination order and encoding kept",
+			raw:   "z=1&q=a+b&r=%2f",
+			query: url.Values{"s": {"x y"}},
+			want:  "z=1&q=a+b&r=%2f&s=x+y",
+		},
+		{
+			name:  "repeated destination parameter merged",
+			raw:   "a=1&a=2",
+			query: url.Values{"a": {"3"}},
+			want:  "a=1&a=2",
+		},
+		{
+			name:     "repeated destination parameter overridden",
+			raw:      "a=1&b=0&a=2",
+			query:    url.Values{"a": {"3", "4"}},
+			override: true,
+			want:     "b=0&a=3&a=4",
+		},
+		{
+			name:     "escaped key overridden",
+			raw:      "utm%5Fsource=x",
+			query:    url.Values{"utm_source": {"y"}},
+			override: true,
+			want:     "utm_source=y",
+		},
+		{
+			name:     "malformed pair kept",
+			raw:      "%zz=1&b=2",
+			query:    url.Values{"b": {"3"}},
+			override: true,
+			want:     "%zz=1&b=3",
+		},
+		{
+			name: "empty pairs dropped",
+			raw:  "a=1&&b=2&",
+			want: "a=1&b=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := passthroughQuery(tt.raw, tt.query, tt.override); got != tt.want {
+				t.Errorf("passthroughQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasDotSegment(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"", false},
+		{"guide/intro", false},
+		{"guide/", false},
+		{".hidden/file", false},
+		{"a/..b/c", false},
+		{"a/...", false},
+		{".", true},
+		{"..", true},
+		{"./a", true},
+		{"a/./b", true},
+		{"../etc/passwd", true},
+		{"a/b/..", true},
+		{"/a/../", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := hasDotSegment(tt.path); got != tt.want {
+				t.Errorf("hasDotSegment(%q) = %t, want %t", tt.path, got, tt.want)
+			}
+		})
+	}
+}
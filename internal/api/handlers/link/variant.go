@@ -0,0 +1,65 @@
+package link
+
+import (
+	"encoding/base64"
+	"hash/fnv"
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// variantCookiePrefix prefixes the name of the cookie remembering the A/B variant
+// assigned to a visitor, followed by the link ID. The cookie holds the variant name
+// encoded with variantEncoding, since names may contain characters invalid in cookies.
+const variantCookiePrefix = "v_"
+
+// variantCookieMaxAge is how long a visitor keeps the assigned variant, in seconds.
+const variantCookieMaxAge = 30 * 24 * 60 * 60
+
+// variantEncoding encodes variant names into cookie values.
+var variantEncoding = base64.RawURLEncoding
+
+// assignVariant picks the A/B variant of the link for the visitor and returns its
+// position, or -1 if the link has no variants. A variant remembered in the cookie is
+// kept while it exists, otherwise the visitor is bucketed by a hash of its IP and
+// user agent so that repeated visits without cookies land on the same variant.
+func assignVariant(c *ginext.Context, link model.Link, cl client) int {
+	if len(link.Variants) == 0 {
+		return -1
+	}
+
+	name := variantCookiePrefix + link.ID.String()
+
+	i := -1
+	if cookie, err := c.Request.Cookie(name); err == nil {
+		if b, err := variantEncoding.DecodeString(cookie.Value); err == nil {
+			i = link.VariantByName(string(b))
+		}
+	}
+
+	if i < 0 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(link.ID.String() + "|" + cl.IP + "|" + cl.UserAgent))
+
+		i = link.PickVariant(h.Sum64())
+		if i < 0 {
+			return -1
+		}
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    variantEncoding.EncodeToString([]byte(link.Variants[i].Name)),
+		Path:     "/",
+		MaxAge:   variantCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Keep browsers from caching the redirect past the assignment, e.g. with 301.
+	c.Header("Cache-Control", "no-store")
+
+	return i
+}
@@ -0,0 +1,189 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/api/respond"
+	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	workspacerepo "github.com/aliskhannn/url-shortener/internal/repository/workspace"
+	workspacesvc "github.com/aliskhannn/url-shortener/internal/service/workspace"
+)
+
+// workspaceService defines the interface that the Handler depends on.
+type workspaceService interface {
+	CreateWorkspace(ctx context.Context, userID uuid.UUID, slug, name string) (model.Workspace, error)
+	ListWorkspaces(ctx context.Context, userID uuid.UUID) ([]model.UserWorkspace, error)
+	ListMembers(ctx context.Context, workspaceID uuid.UUID) ([]model.WorkspaceMember, error)
+	SetMemberRole(ctx context.Context, workspaceID, userID uuid.UUID, role string) (model.WorkspaceMember, error)
+	RemoveMember(ctx context.Context, workspaceID, userID uuid.UUID) error
+}
+
+// Handler handles HTTP requests related to workspaces.
+type Handler struct {
+	validator        *validator.Validate
+	workspaceService workspaceService
+}
+
+// NewHandler creates a new Handler instance.
+func NewHandler(v *validator.Validate, ws workspaceService) *Handler {
+	return &Handler{validator: v, workspaceService: ws}
+}
+
+// CreateRequest represents the expected JSON payload for creating a workspace.
+type CreateRequest struct {
+	Slug string `json:"slug" validate:"required"`
+	Name string `json:"name" validate:"max=128"`
+}
+
+// MemberRequest represents the expected JSON payload for adding a member or changing a member's role.
+type MemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+// CreateWorkspace handles POST /workspaces requests.
+// It creates a new workspace owned by the authenticated user.
+func (h *Handler) CreateWorkspace(c *ginext.Context) {
+	var req CreateRequest
+
+	// Decode JSON request body into CreateRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	ws, err := h.workspaceService.CreateWorkspace(c.Request.Context(), middleware.OwnerID(c), req.Slug, req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, workspacesvc.ErrInvalidSlug):
+			zlog.Logger.Warn().Str("slug", req.Slug).Msg("invalid workspace slug")
+			respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("slug must be 2-32 lowercase letters, digits or hyphens"))
+		case errors.Is(err, workspacerepo.ErrSlugTaken):
+			zlog.Logger.Warn().Str("slug", req.Slug).Msg("workspace slug already taken")
+			respond.Fail(c.Writer, http.StatusConflict, fmt.Errorf("workspace slug already taken"))
+		default:
+			zlog.Logger.Error().Err(err).Str("slug", req.Slug).Msg("failed to create workspace")
+			respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	respond.Created(c.Writer, ws)
+}
+
+// ListWorkspaces handles GET /workspaces requests.
+// It returns all workspaces of the authenticated user together with the user's roles.
+func (h *Handler) ListWorkspaces(c *ginext.Context) {
+	workspaces, err := h.workspaceService.ListWorkspaces(c.Request.Context(), middleware.OwnerID(c))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to list workspaces")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, workspaces)
+}
+
+// ListMembers handles GET /workspace/members requests.
+// It returns all members of the current workspace.
+func (h *Handler) ListMembers(c *ginext.Context) {
+	ws := middleware.Workspace(c)
+
+	members, err := h.workspaceService.ListMembers(c.Request.Context(), ws.ID)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("workspace", ws.Slug).Msg("failed to list workspace members")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, members)
+}
+
+// SetMember handles PUT /workspace/members/:user_id requests.
+// It adds the user to the current workspace or changes the user's role.
+func (h *Handler) SetMember(c *ginext.Context) {
+	ws := middleware.Workspace(c)
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("invalid user id")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	var req MemberRequest
+
+	// Decode JSON request body into MemberRequest struct.
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to decode request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate request body")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	m, err := h.workspaceService.SetMemberRole(c.Request.Context(), ws.ID, userID, req.Role)
+	if err != nil {
+		if errors.Is(err, workspacesvc.ErrLastOwner) {
+			zlog.Logger.Warn().Str("workspace", ws.Slug).Msg("attempt to demote last workspace owner")
+			respond.Fail(c.Writer, http.StatusConflict, fmt.Errorf("workspace must keep at least one owner"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Str("workspace", ws.Slug).Msg("failed to set workspace member")
+		respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	respond.OK(c.Writer, m)
+}
+
+// RemoveMember handles DELETE /workspace/members/:user_id requests.
+// It removes the user from the current workspace.
+func (h *Handler) RemoveMember(c *ginext.Context) {
+	ws := middleware.Workspace(c)
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("invalid user id")
+		respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(c.Request.Context(), ws.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, workspacerepo.ErrMemberNotFound):
+			zlog.Logger.Warn().Str("workspace", ws.Slug).Msg("workspace member not found")
+			respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("workspace member not found"))
+		case errors.Is(err, workspacesvc.ErrLastOwner):
+			zlog.Logger.Warn().Str("workspace", ws.Slug).Msg("attempt to remove last workspace owner")
+			respond.Fail(c.Writer, http.StatusConflict, fmt.Errorf("workspace must keep at least one owner"))
+		default:
+			zlog.Logger.Error().Err(err).Str("workspace", ws.Slug).Msg("failed to remove workspace member")
+			respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	respond.NoContent(c.Writer)
+}
@@ -45,6 +45,11 @@ func Created(w http.ResponseWriter, result interface{}) {
 	JSON(w, http.StatusCreated, Success{Result: result})
 }
 
+// NoContent sends a 204 No Content response without a body.
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Fail sends an error response with the specified HTTP status code.
 //
 // The error message is wrapped in an Error struct.
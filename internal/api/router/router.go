@@ -1,36 +1,163 @@
 package router
 
 import (
+	"expvar"
+
 	"github.com/wb-go/wbf/ginext"
 
 	"github.com/aliskhannn/url-shortener/internal/api/handlers/analytics"
+	"github.com/aliskhannn/url-shortener/internal/api/handlers/apikey"
+	"github.com/aliskhannn/url-shortener/internal/api/handlers/domain"
 	"github.com/aliskhannn/url-shortener/internal/api/handlers/link"
+	"github.com/aliskhannn/url-shortener/internal/api/handlers/workspace"
+	"github.com/aliskhannn/url-shortener/internal/config"
 	"github.com/aliskhannn/url-shortener/internal/middleware"
+	"github.com/aliskhannn/url-shortener/internal/model"
 )
 
+// Handlers groups HTTP handlers served by the router.
+type Handlers struct {
+	Link      *link.Handler
+	Analytics *analytics.Handler
+	APIKey    *apikey.Handler
+	Workspace *workspace.Handler
+	Domain    *domain.Handler
+}
+
+// Middlewares groups middlewares guarding non-public routes.
+type Middlewares struct {
+	Auth      ginext.HandlerFunc // authenticates API keys, see middleware.AuthMiddleware
+	Admin     ginext.HandlerFunc // checks the admin token, see middleware.AdminMiddleware
+	ClientIP  ginext.HandlerFunc // resolves client IPs behind trusted proxies, see middleware.ClientIPMiddleware
+	Workspace ginext.HandlerFunc // resolves the workspace, see middleware.WorkspaceMiddleware
+}
+
 // New creates a new Gin engine with routes and middlewares for the notification API.
 //
-// It applies standard middlewares (CORS, logging, recovery) and sets up the
-// /api group with the following routes:
-//   - POST	/api/shorten				-> linkHandler.ShortenLink
-//   - GET	/api/s/:short_url" 			-> linkHandler.RedirectLink
-//   - GET	/api/analytics/:short_url 	-> analyticsHandler.GetAnalytics
-func New(linkHandler *link.Handler, analyticsHandler *analytics.Handler) *ginext.Engine {
+// It applies standard middlewares (client IP, CORS, logging, recovery) and sets up the
+// /api group with the following public routes:
+//   - GET	/api/s/:alias						-> Link.RedirectLink (default workspace)
+//   - GET	/api/s/:alias/*rest					-> Link.RedirectLink (default workspace)
+//   - GET	/api/w/:workspace/:alias			-> Link.RedirectLink
+//   - GET	/api/w/:workspace/:alias/*rest		-> Link.RedirectLink
+//
+// On a verified custom domain /api/s/:alias resolves aliases of that domain.
+// If cfg.RootRedirects is set, the same redirect is also served at the root:
+//   - GET	/:alias								-> Link.RedirectLink
+//   - GET	/:alias/*rest						-> Link.RedirectLink
+//
+// Redirects also answer HEAD requests, which are recorded as bot clicks.
+//
+// Routes requiring an API key:
+//   - GET	/api/keys							-> APIKey.ListKeys
+//   - POST	/api/keys							-> APIKey.CreateKey
+//   - DELETE	/api/keys/:id						-> APIKey.RevokeKey
+//   - GET	/api/workspaces						-> Workspace.ListWorkspaces
+//   - POST	/api/workspaces						-> Workspace.CreateWorkspace
+//
+// Routes requiring an API key and the X-Workspace header, with the minimal member role:
+//   - GET	/api/links							-> Link.ListLinks (viewer)
+//   - GET	/api/links/:alias					-> Link.GetLink (viewer)
+//   - GET	/api/links/:alias/qr				-> Link.QRCode (viewer)
+//   - GET	/api/analytics/:alias				-> Analytics.GetAnalytics (viewer)
+//   - GET	/api/analytics/:alias/live			-> Analytics.LiveClicks (viewer)
+//   - GET	/api/workspace/members				-> Workspace.ListMembers (viewer)
+//   - GET	/api/domains						-> Domain.ListDomains (viewer)
+//   - POST	/api/shorten						-> Link.ShortenLink (editor)
+//   - PATCH	/api/links/:alias					-> Link.UpdateLink (editor)
+//   - DELETE	/api/links/:alias					-> Link.DeleteLink (editor)
+//   - PUT	/api/workspace/members/:user_id		-> Workspace.SetMember (owner)
+//   - DELETE	/api/workspace/members/:user_id		-> Workspace.RemoveMember (owner)
+//   - POST	/api/domains						-> Domain.RegisterDomain (owner)
+//   - POST	/api/domains/:hostname/verify		-> Domain.VerifyDomain (owner)
+//   - DELETE	/api/domains/:hostname				-> Domain.DeleteDomain (owner)
+//
+// Routes requiring the admin token:
+//   - POST	/api/admin/keys						-> APIKey.IssueKey
+//   - GET	/api/admin/metrics					-> expvar metrics, e.g. of the click ingest queue
+//   - POST	/api/admin/privacy/erasure			-> Analytics.EraseIP
+//   - POST	/api/admin/links/claim				-> Link.ClaimLinks
+func New(h Handlers, mw Middlewares, cfg config.Server) *ginext.Engine {
 	// Create a new Gin engine using the extended gin wrapper.
 	e := ginext.New()
 
-	// Apply middlewares: CORS, logger, and recovery.
+	// Client IPs are resolved by mw.ClientIP, so forwarding headers are not trusted again.
+	e.ForwardedByClientIP = false
+
+	// Apply middlewares: client IP, CORS, logger, and recovery.
+	e.Use(mw.ClientIP)
 	e.Use(middleware.CORSMiddleware())
 	e.Use(ginext.Logger())
 	e.Use(ginext.Recovery())
 
-	// Create an API group for notifications.
+	// Create an API group for public requests.
 	api := e.Group("/api")
 	{
-		api.POST("/shorten", linkHandler.ShortenLink)
-		api.GET("/s/:alias", linkHandler.RedirectLink)
-		api.GET("/analytics/:alias", analyticsHandler.GetAnalytics)
+		for _, path := range []string{"/s/:alias", "/s/:alias/*rest", "/w/:workspace/:alias", "/w/:workspace/:alias/*rest"} {
+			api.GET(path, h.Link.RedirectLink)
+			api.HEAD(path, h.Link.RedirectLink)
+		}
+	}
+
+	// Serve short links at the root, reserved aliases keep it from shadowing other paths.
+	if cfg.RootRedirects {
+		for _, path := range []string{"/:alias", "/:alias/*rest"} {
+			e.GET(path, h.Link.RedirectLink)
+			e.HEAD(path, h.Link.RedirectLink)
+		}
+	}
+
+	// Create an API group for authenticated requests.
+	private := e.Group("/api", mw.Auth)
+	{
+		private.GET("/keys", h.APIKey.ListKeys)
+		private.POST("/keys", h.APIKey.CreateKey)
+		private.DELETE("/keys/:id", h.APIKey.RevokeKey)
+		private.GET("/workspaces", h.Workspace.ListWorkspaces)
+		private.POST("/workspaces", h.Workspace.CreateWorkspace)
+	}
+
+	// Create API groups for requests within a workspace, by minimal member role.
+	viewer := e.Group("/api", mw.Auth, mw.Workspace, middleware.RequireRole(model.RoleViewer))
+	{
+		viewer.GET("/links", h.Link.ListLinks)
+		viewer.GET("/links/:alias", h.Link.GetLink)
+		viewer.GET("/links/:alias/qr", h.Link.QRCode)
+		viewer.GET("/analytics/:alias", h.Analytics.GetAnalytics)
+		viewer.GET("/analytics/:alias/live", h.Analytics.LiveClicks)
+		viewer.GET("/workspace/members", h.Workspace.ListMembers)
+		viewer.GET("/domains", h.Domain.ListDomains)
+	}
+
+	editor := e.Group("/api", mw.Auth, mw.Workspace, middleware.RequireRole(model.RoleEditor))
+	{
+		editor.POST("/shorten", h.Link.ShortenLink)
+		editor.PATCH("/links/:alias", h.Link.UpdateLink)
+		editor.DELETE("/links/:alias", h.Link.DeleteLink)
+	}
+
+	owner := e.Group("/api", mw.Auth, mw.Workspace, middleware.RequireRole(model.RoleOwner))
+	{
+		owner.PUT("/workspace/members/:user_id", h.Workspace.SetMember)
+		owner.DELETE("/workspace/members/:user_id", h.Workspace.RemoveMember)
+		owner.POST("/domains", h.Domain.RegisterDomain)
+		owner.POST("/domains/:hostname/verify", h.Domain.VerifyDomain)
+		owner.DELETE("/domains/:hostname", h.Domain.DeleteDomain)
+	}
+
+	// Create an API group for administrative requests.
+	adm := e.Group("/api/admin", mw.Admin)
+	{
+		adm.POST("/keys", h.APIKey.IssueKey)
+		adm.GET("/metrics", metrics)
+		adm.POST("/privacy/erasure", h.Analytics.EraseIP)
+		adm.POST("/links/claim", h.Link.ClaimLinks)
 	}
 
 	return e
 }
+
+// metrics serves the expvar metrics of the process as JSON.
+func metrics(c *ginext.Context) {
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
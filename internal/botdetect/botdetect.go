@@ -0,0 +1,80 @@
+// Package botdetect tells bots, crawlers and link preview fetchers from human visitors.
+package botdetect
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/mssola/user_agent"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+)
+
+// Reasons a request is classified as a bot.
+const (
+	ReasonUserAgent     = "user_agent"     // known bot or matching a configured pattern
+	ReasonPreview       = "preview"        // link preview fetcher of a chat app or social network
+	ReasonHeadRequest   = "head_request"   // HEAD request, browsers follow links with GET
+	ReasonMissingAccept = "missing_accept" // no Accept header, which browsers always send
+)
+
+// Classifier classifies requests by the configured rules.
+type Classifier struct {
+	patterns []*regexp.Regexp
+	previews []string // lowercase user agent substrings of preview fetchers
+	cfg      config.Bots
+}
+
+// New creates a new Classifier instance, compiling the user agent patterns.
+func New(cfg config.Bots) (*Classifier, error) {
+	c := &Classifier{cfg: cfg}
+
+	for _, p := range cfg.UserAgentPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("compile user agent pattern %q: %w", p, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+
+	for _, p := range cfg.PreviewFetchers {
+		c.previews = append(c.previews, strings.ToLower(p))
+	}
+
+	return c, nil
+}
+
+// Classify returns the reason the request comes from a bot, or an empty string if it
+// looks like a human visitor.
+func (c *Classifier) Classify(r *http.Request) string {
+	ua := r.UserAgent()
+	lower := strings.ToLower(ua)
+
+	for _, p := range c.previews {
+		if strings.Contains(lower, p) {
+			return ReasonPreview
+		}
+	}
+
+	if ua == "" || user_agent.New(ua).Bot() {
+		return ReasonUserAgent
+	}
+
+	for _, re := range c.patterns {
+		if re.MatchString(ua) {
+			return ReasonUserAgent
+		}
+	}
+
+	if c.cfg.HeadRequests && r.Method == http.MethodHead {
+		return ReasonHeadRequest
+	}
+
+	if c.cfg.MissingAccept && r.Header.Get("Accept") == "" {
+		return ReasonMissingAccept
+	}
+
+	return ""
+}
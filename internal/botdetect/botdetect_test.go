@@ -0,0 +1,75 @@
+package botdetect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+)
+
+const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+func TestClassify(t *testing.T) {
+	c, err := New(config.Bots{
+		UserAgentPatterns: []string{`python-requests`, `^curl/`},
+		PreviewFetchers:   []string{"TelegramBot", "Slackbot-LinkExpanding"},
+		HeadRequests:      true,
+		MissingAccept:     true,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		userAgent string
+		accept    string
+		want      string
+	}{
+		{"browser", http.MethodGet, chrome, "text/html", ""},
+		{"empty user agent", http.MethodGet, "", "text/html", ReasonUserAgent},
+		{"known crawler", http.MethodGet, "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "*/*", ReasonUserAgent},
+		{"configured pattern", http.MethodGet, "python-requests/2.31.0", "*/*", ReasonUserAgent},
+		{"pattern case insensitive", http.MethodGet, "CURL/8.4.0", "*/*", ReasonUserAgent},
+		{"preview fetcher", http.MethodGet, "TelegramBot (like TwitterBot)", "*/*", ReasonPreview},
+		{"preview fetcher case insensitive", http.MethodGet, "slackbot-linkexpanding 1.0", "*/*", ReasonPreview},
+		{"head request", http.MethodHead, chrome, "text/html", ReasonHeadRequest},
+		{"missing accept", http.MethodGet, chrome, "", ReasonMissingAccept},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/abc", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			if got := c.Classify(r); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyDisabledChecks(t *testing.T) {
+	c, err := New(config.Bots{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodHead, "/abc", nil)
+	r.Header.Set("User-Agent", chrome)
+
+	if got := c.Classify(r); got != "" {
+		t.Errorf("Classify() = %q, want human", got)
+	}
+}
+
+func TestNewInvalidPattern(t *testing.T) {
+	if _, err := New(config.Bots{UserAgentPatterns: []string{"("}}); err == nil {
+		t.Error("New() error = nil, want an error")
+	}
+}
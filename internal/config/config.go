@@ -11,15 +11,29 @@ import (
 
 // Config holds the main configuration for the application.
 type Config struct {
-	Server   Server         `mapstructure:"server"`
-	Database Database       `mapstructure:"database"`
-	Redis    Redis          `mapstructure:"redis"`
-	Retry    retry.Strategy `mapstructure:"retry"`
+	Server     Server         `mapstructure:"server"`
+	Database   Database       `mapstructure:"database"`
+	Redis      Redis          `mapstructure:"redis"`
+	Retry      retry.Strategy `mapstructure:"retry"`
+	Sweeper    Sweeper        `mapstructure:"sweeper"`
+	Auth       Auth           `mapstructure:"auth"`
+	QR         QR             `mapstructure:"qr"`
+	GeoIP      GeoIP          `mapstructure:"geoip"`
+	Bots       Bots           `mapstructure:"bots"`
+	Ingest     Ingest         `mapstructure:"ingest"`
+	Stream     Stream         `mapstructure:"stream"`
+	Partitions Partitions     `mapstructure:"partitions"`
+	Privacy    Privacy        `mapstructure:"privacy"`
 }
 
 // Server holds HTTP server-related configuration.
 type Server struct {
-	HTTPPort string `mapstructure:"http_port"` // HTTP port to listen on
+	HTTPPort        string   `mapstructure:"http_port"`        // HTTP port to listen on
+	BaseURL         string   `mapstructure:"base_url"`         // public base URL of short links, e.g. https://sho.rt
+	RootRedirects   bool     `mapstructure:"root_redirects"`   // serve redirects at /:alias in addition to /api/s/:alias
+	ReservedAliases []string `mapstructure:"reserved_aliases"` // aliases colliding with application paths
+	TrustedProxies  []string `mapstructure:"trusted_proxies"`  // CIDRs of proxies whose forwarding headers tell the client IP
+	ClientIPHeader  string   `mapstructure:"client_ip_header"` // forwarding header set by the trusted proxies, empty to ignore all
 }
 
 // Database holds database master and slave configuration.
@@ -49,6 +63,79 @@ type Redis struct {
 	Database string `mapstructure:"database"`
 }
 
+// Auth holds authentication configuration.
+type Auth struct {
+	AdminToken string `mapstructure:"admin_token"` // bearer token for administrative endpoints, empty disables them
+}
+
+// QR holds configuration of QR code generation.
+type QR struct {
+	LogoHosts []string `mapstructure:"logo_hosts"` // hosts logos may be downloaded from, empty disables logos
+}
+
+// GeoIP holds configuration of offline IP geolocation.
+type GeoIP struct {
+	CityDB         string        `mapstructure:"city_db"`         // path to a City or Country .mmdb file, empty disables locations
+	ASNDB          string        `mapstructure:"asn_db"`          // path to an ASN .mmdb file, empty disables ASN lookups
+	ReloadInterval time.Duration `mapstructure:"reload_interval"` // how often to check database files for changes
+}
+
+// Bots holds configuration of the classifier telling bots from human visitors.
+type Bots struct {
+	UserAgentPatterns []string `mapstructure:"user_agent_patterns"` // case-insensitive regular expressions of bot user agents
+	PreviewFetchers   []string `mapstructure:"preview_fetchers"`    // case-insensitive user agent substrings of link preview fetchers
+	HeadRequests      bool     `mapstructure:"head_requests"`       // classify HEAD requests as bots
+	MissingAccept     bool     `mapstructure:"missing_accept"`      // classify requests without an Accept header as bots
+}
+
+// Ingest holds configuration of the pipeline saving click events in batches.
+type Ingest struct {
+	QueueSize     int           `mapstructure:"queue_size"`     // max number of events waiting to be saved
+	Workers       int           `mapstructure:"workers"`        // number of workers saving batches concurrently
+	BatchSize     int           `mapstructure:"batch_size"`     // max number of events saved at once
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // max time an incomplete batch waits
+	Policy        string        `mapstructure:"policy"`         // "drop_newest", "drop_oldest" or "block" when the queue is full
+	BlockTimeout  time.Duration `mapstructure:"block_timeout"`  // max wait for room in the queue with the "block" policy
+	WriteTimeout  time.Duration `mapstructure:"write_timeout"`  // max duration of saving a batch, including retries
+}
+
+// Stream holds configuration of the Redis Stream carrying click events to the worker.
+type Stream struct {
+	Enabled       bool          `mapstructure:"enabled"`         // append clicks to the stream instead of the in-process queue
+	Key           string        `mapstructure:"key"`             // stream of click events
+	DeadLetterKey string        `mapstructure:"dead_letter_key"` // stream of events that could not be saved
+	Group         string        `mapstructure:"group"`           // consumer group of workers
+	MaxLen        int64         `mapstructure:"max_len"`         // approximate max length of the streams, 0 for unlimited
+	AddTimeout    time.Duration `mapstructure:"add_timeout"`     // max time of appending a batch before saving it directly
+	BatchSize     int           `mapstructure:"batch_size"`      // max number of events read and saved at once
+	Block         time.Duration `mapstructure:"block"`           // max time a read waits for new events
+	RetryInterval time.Duration `mapstructure:"retry_interval"`  // time after which unacknowledged events are retried
+	MaxDeliveries int           `mapstructure:"max_deliveries"`  // deliveries after which events are dead-lettered
+	WriteTimeout  time.Duration `mapstructure:"write_timeout"`   // max duration of saving a batch, including retries
+}
+
+// Sweeper holds configuration of the background job that cleans up expired links.
+type Sweeper struct {
+	Interval    time.Duration `mapstructure:"interval"`     // how often to look for expired links
+	GracePeriod time.Duration `mapstructure:"grace_period"` // how long expired links are kept before sweeping
+	Mode        string        `mapstructure:"mode"`         // "archive" to keep rows, "delete" to purge them
+	BatchSize   int           `mapstructure:"batch_size"`   // max number of links processed per query
+}
+
+// Partitions holds configuration of the background job managing monthly partitions of clicks.
+type Partitions struct {
+	Interval        time.Duration `mapstructure:"interval"`         // how often partitions are created and expired
+	Ahead           int           `mapstructure:"ahead"`            // number of future months with partitions created ahead
+	RetentionMonths int           `mapstructure:"retention_months"` // months raw clicks are kept, 0 to keep them forever; rollups are always kept
+	Mode            string        `mapstructure:"mode"`             // "archive" to detach expired partitions, "drop" to delete them
+}
+
+// Privacy holds configuration of how personal data of clicks is stored.
+type Privacy struct {
+	Mode            string `mapstructure:"mode"`               // "full", "truncate" or "hash", see model.PrivacyModeFull; links may override it
+	HonorDoNotTrack bool   `mapstructure:"honor_do_not_track"` // skip personal fields of visitors sending DNT or Sec-GPC
+}
+
 // DSN returns the PostgreSQL DSN string for connecting to this database node.
 func (n DatabaseNode) DSN() string {
 	return fmt.Sprintf(
@@ -71,6 +158,16 @@ func mustBindEnv() {
 		"redis.address":  "REDIS_ADDRESS",
 		"redis.password": "REDIS_PASSWORD",
 		"redis.database": "REDIS_DATABASE",
+
+		"server.base_url":         "BASE_URL",
+		"server.client_ip_header": "CLIENT_IP_HEADER",
+
+		"geoip.city_db": "GEOIP_CITY_DB",
+		"geoip.asn_db":  "GEOIP_ASN_DB",
+
+		"auth.admin_token": "ADMIN_TOKEN",
+
+		"stream.enabled": "STREAM_ENABLED",
 	}
 
 	for key, env := range bindings {
@@ -0,0 +1,249 @@
+// Package geoip resolves client IP addresses to locations using local MaxMind-format
+// (.mmdb) databases. Databases are reopened when their files are replaced on disk.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+)
+
+// Maximal lengths in bytes of location names, matching the analytics columns storing them.
+const (
+	maxNameLength = 128 // region and city
+	maxOrgLength  = 255 // autonomous system organization
+)
+
+// Location describes where an IP address is located.
+type Location struct {
+	Country string // ISO 3166-1 alpha-2 country code
+	Region  string // name of the first-level subdivision
+	City    string // city name
+	ASN     uint   // autonomous system number
+	ASOrg   string // autonomous system organization
+}
+
+// cityRecord holds fields decoded from City and Country databases.
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// asnRecord holds fields decoded from ASN databases.
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// database is a reloadable .mmdb file.
+type database struct {
+	path    string
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+// Resolver looks up locations of IP addresses. The zero databases configuration
+// yields a Resolver returning empty locations.
+type Resolver struct {
+	mu       sync.RWMutex // guards databases, which Run reloads and Close closes
+	city     database
+	asn      database
+	closed   bool
+	interval time.Duration
+}
+
+// New creates a Resolver opening the configured databases. Missing database files
+// are not an error: they are opened by Run once they appear.
+func New(cfg config.GeoIP) (*Resolver, error) {
+	r := &Resolver{
+		city:     database{path: cfg.CityDB},
+		asn:      database{path: cfg.ASNDB},
+		interval: cfg.ReloadInterval,
+	}
+
+	for _, db := range []*database{&r.city, &r.asn} {
+		if db.path == "" {
+			continue
+		}
+
+		if err := db.open(); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				zlog.Logger.Warn().Str("path", db.path).Msg("geoip database not found")
+				continue
+			}
+
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Lookup returns the location of the IP address. Unknown or invalid addresses
+// yield an empty location.
+func (r *Resolver) Lookup(ip string) Location {
+	var loc Location
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return loc
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.city.reader != nil {
+		var rec cityRecord
+		if err := r.city.reader.Lookup(addr, &rec); err == nil {
+			loc.Country = rec.Country.ISOCode
+			loc.City = truncate(rec.City.Names["en"], maxNameLength)
+			if len(rec.Subdivisions) > 0 {
+				loc.Region = truncate(rec.Subdivisions[0].Names["en"], maxNameLength)
+			}
+		}
+	}
+
+	if r.asn.reader != nil {
+		var rec asnRecord
+		if err := r.asn.reader.Lookup(addr, &rec); err == nil {
+			loc.ASN = rec.Number
+			loc.ASOrg = truncate(rec.Organization, maxOrgLength)
+		}
+	}
+
+	return loc
+}
+
+// Run reopens databases whose files changed every reload interval until ctx is cancelled.
+func (r *Resolver) Run(ctx context.Context) {
+	if r.interval <= 0 || (r.city.path == "" && r.asn.path == "") {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reload(&r.city)
+			r.reload(&r.asn)
+		}
+	}
+}
+
+// Close closes the databases, waiting for running lookups. Later lookups return empty
+// locations and databases are no longer reloaded.
+func (r *Resolver) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	for _, db := range []*database{&r.city, &r.asn} {
+		if db.reader != nil {
+			_ = db.reader.Close()
+			db.reader = nil
+		}
+	}
+}
+
+// reload reopens the database if its file was replaced or modified.
+func (r *Resolver) reload(db *database) {
+	if db.path == "" {
+		return
+	}
+
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		// Keep serving the loaded database while its file is being replaced.
+		if !errors.Is(err, fs.ErrNotExist) {
+			zlog.Logger.Warn().Err(err).Str("path", db.path).Msg("failed to stat geoip database")
+		}
+		return
+	}
+
+	r.mu.RLock()
+	unchanged := r.closed || (fi.ModTime().Equal(db.modTime) && fi.Size() == db.size)
+	r.mu.RUnlock()
+
+	if unchanged {
+		return
+	}
+
+	next := database{path: db.path}
+	if err := next.open(); err != nil {
+		zlog.Logger.Error().Err(err).Str("path", db.path).Msg("failed to reload geoip database")
+		return
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		_ = next.reader.Close()
+		return
+	}
+	prev := db.reader
+	*db = next
+	r.mu.Unlock()
+
+	if prev != nil {
+		_ = prev.Close()
+	}
+
+	zlog.Logger.Info().Str("path", db.path).Msg("reloaded geoip database")
+}
+
+// open opens the database file and records its modification time and size.
+func (db *database) open() error {
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return fmt.Errorf("stat geoip database %s: %w", db.path, err)
+	}
+
+	reader, err := maxminddb.Open(db.path)
+	if err != nil {
+		return fmt.Errorf("open geoip database %s: %w", db.path, err)
+	}
+
+	db.reader = reader
+	db.modTime = fi.ModTime()
+	db.size = fi.Size()
+
+	return nil
+}
+
+// truncate cuts s to at most n bytes without splitting UTF-8 characters.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,261 @@
+// Package live fans out clicks on links to real-time subscribers of all instances
+// through Redis pub/sub.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// channelPrefix prefixes Redis channels of clicks, followed by the link ID.
+const channelPrefix = "clicks:live:"
+
+// subscriberBuffer is the number of clicks buffered per subscriber, clicks arriving
+// while the buffer of a slow subscriber is full are dropped for it.
+const subscriberBuffer = 64
+
+// Publisher publishes clicks to Redis, to be delivered by the hubs of all instances.
+type Publisher struct {
+	rdb *redis.Client
+}
+
+// NewPublisher creates a new Publisher instance.
+func NewPublisher(rdb *redis.Client) *Publisher {
+	return &Publisher{rdb: rdb}
+}
+
+// Publish publishes human clicks of the events to subscribers of their links on all instances.
+func (p *Publisher) Publish(ctx context.Context, events []model.Analytics) error {
+	pipe := p.rdb.Pipeline()
+
+	n := 0
+	for _, event := range events {
+		if event.IsBot {
+			continue
+		}
+
+		b, err := json.Marshal(model.LiveClick{
+			Time:     event.CreatedAt,
+			Device:   event.Device,
+			Country:  event.Country,
+			Referrer: event.ReferrerDomain,
+		})
+		if err != nil {
+			return fmt.Errorf("encode live click: %w", err)
+		}
+
+		pipe.Publish(ctx, channel(event.LinkID), b)
+		n++
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("publish live clicks: %w", err)
+	}
+
+	return nil
+}
+
+// Hub delivers clicks of subscribed links published by any instance to local subscribers
+// over a single Redis connection, subscribing to the channel of a link while it has local
+// subscribers.
+type Hub struct {
+	pubsub *goredis.PubSub
+
+	mu     sync.Mutex // guards subs and closed, never held across Redis calls
+	subs   map[uuid.UUID]map[chan model.LiveClick]struct{}
+	closed bool
+
+	syncMu     sync.Mutex // serializes Redis (un)subscriptions
+	subscribed map[uuid.UUID]bool
+}
+
+// New creates a new Hub instance holding a Redis pub/sub connection. Clicks are delivered
+// to subscribers once Run is started.
+func New(rdb *redis.Client) *Hub {
+	return &Hub{
+		pubsub:     rdb.Subscribe(context.Background()),
+		subs:       make(map[uuid.UUID]map[chan model.LiveClick]struct{}),
+		subscribed: make(map[uuid.UUID]bool),
+	}
+}
+
+// Run delivers published clicks to local subscribers until ctx is cancelled,
+// then closes the channels of all subscribers.
+func (h *Hub) Run(ctx context.Context) {
+	messages := h.pubsub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.close()
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				h.close()
+				return
+			}
+
+			h.deliver(msg)
+		}
+	}
+}
+
+// deliver sends the click of the message to local subscribers of its link.
+func (h *Hub) deliver(msg *goredis.Message) {
+	linkID, err := uuid.Parse(strings.TrimPrefix(msg.Channel, channelPrefix))
+	if err != nil {
+		return
+	}
+
+	var click model.LiveClick
+	if err := json.Unmarshal([]byte(msg.Payload), &click); err != nil {
+		zlog.Logger.Warn().Err(err).Str("channel", msg.Channel).Msg("failed to decode live click")
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[linkID] {
+		select {
+		case ch <- click:
+		default: // slow subscriber
+		}
+	}
+}
+
+// close stops delivering clicks and closes the channels of all subscribers.
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subs {
+		for ch := range subs {
+			close(ch)
+		}
+	}
+
+	h.subs = make(map[uuid.UUID]map[chan model.LiveClick]struct{})
+	h.closed = true
+
+	if err := h.pubsub.Close(); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to close live clicks subscription")
+	}
+}
+
+// Subscribe returns a channel of clicks on the link and a function cancelling the
+// subscription. The channel is closed when the subscription is cancelled or the hub stops.
+func (h *Hub) Subscribe(ctx context.Context, linkID uuid.UUID) (<-chan model.LiveClick, func(), error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, nil, fmt.Errorf("live clicks hub is closed")
+	}
+
+	subs, ok := h.subs[linkID]
+	if !ok {
+		subs = make(map[chan model.LiveClick]struct{})
+		h.subs[linkID] = subs
+	}
+
+	ch := make(chan model.LiveClick, subscriberBuffer)
+	subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { h.unsubscribe(linkID, ch) })
+	}
+
+	// Subscribing to Redis outside of mu keeps deliveries to other links flowing meanwhile.
+	if err := h.sync(ctx, linkID); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("subscribe to live clicks: %w", err)
+	}
+
+	return ch, cancel, nil
+}
+
+// unsubscribe removes the subscriber of the link, unsubscribing from the channel of the
+// link when it was the last one.
+func (h *Hub) unsubscribe(linkID uuid.UUID, ch chan model.LiveClick) {
+	h.mu.Lock()
+	subs, ok := h.subs[linkID]
+	if !ok {
+		h.mu.Unlock()
+		return // closed by the hub
+	}
+
+	if _, ok := subs[ch]; !ok {
+		h.mu.Unlock()
+		return
+	}
+
+	delete(subs, ch)
+	close(ch)
+
+	last := len(subs) == 0
+	if last {
+		delete(h.subs, linkID)
+	}
+	h.mu.Unlock()
+
+	if !last {
+		return
+	}
+
+	if err := h.sync(context.Background(), linkID); err != nil {
+		zlog.Logger.Warn().Err(err).Str("link_id", linkID.String()).Msg("failed to unsubscribe from live clicks")
+	}
+}
+
+// sync subscribes to the Redis channel of the link if it has local subscribers and
+// unsubscribes from it otherwise. Calls are serialized, so concurrent subscriptions and
+// cancellations of the link end up in the state of the last one.
+func (h *Hub) sync(ctx context.Context, linkID uuid.UUID) error {
+	h.syncMu.Lock()
+	defer h.syncMu.Unlock()
+
+	h.mu.Lock()
+	want, closed := len(h.subs[linkID]) > 0, h.closed
+	h.mu.Unlock()
+
+	if closed || want == h.subscribed[linkID] {
+		return nil
+	}
+
+	if want {
+		if err := h.pubsub.Subscribe(ctx, channel(linkID)); err != nil {
+			return err
+		}
+
+		h.subscribed[linkID] = true
+		return nil
+	}
+
+	if err := h.pubsub.Unsubscribe(ctx, channel(linkID)); err != nil {
+		return err
+	}
+
+	delete(h.subscribed, linkID)
+	return nil
+}
+
+// channel returns the Redis channel of clicks on the link.
+func channel(linkID uuid.UUID) string {
+	return channelPrefix + linkID.String()
+}
@@ -0,0 +1,209 @@
+package live
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/redis"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// newHub starts a hub over a fresh Redis and returns it with the Redis and a publisher.
+func newHub(t *testing.T) (*Hub, *miniredis.Miniredis, *Publisher) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := &redis.Client{Client: goredis.NewClient(&goredis.Options{Addr: mr.Addr()})}
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	h := New(rdb)
+	go h.Run(ctx)
+
+	return h, mr, NewPublisher(rdb)
+}
+
+// waitSubscribers waits until Redis has n subscribers of the channel of the link.
+func waitSubscribers(t *testing.T, mr *miniredis.Miniredis, linkID uuid.UUID, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for mr.PubSubNumSub(channel(linkID))[channel(linkID)] != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("redis subscribers of %s = %d, want %d", linkID, mr.PubSubNumSub(channel(linkID))[channel(linkID)], n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// receive returns the next click of the channel.
+func receive(t *testing.T, ch <-chan model.LiveClick) model.LiveClick {
+	t.Helper()
+
+	select {
+	case click, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed, want a click")
+		}
+		return click
+	case <-time.After(5 * time.Second):
+		t.Fatal("no click received")
+		return model.LiveClick{}
+	}
+}
+
+func TestHubDeliversPublishedClicks(t *testing.T) {
+	h, mr, p := newHub(t)
+	ctx := context.Background()
+	linkID, otherID := uuid.New(), uuid.New()
+
+	clicks, cancel, err := h.Subscribe(ctx, linkID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer cancel()
+	waitSubscribers(t, mr, linkID, 1)
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []model.Analytics{
+		{LinkID: linkID, IsBot: true, Device: "bot"},
+		{LinkID: otherID, Device: "tablet"},
+		{LinkID: linkID, CreatedAt: at, Device: "mobile", Country: "DE", ReferrerDomain: "t.co"},
+	}
+	if err := p.Publish(ctx, events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	want := model.LiveClick{Time: at, Device: "mobile", Country: "DE", Referrer: "t.co"}
+	if got := receive(t, clicks); got != want {
+		t.Errorf("received %+v, want %+v", got, want)
+	}
+
+	select {
+	case click := <-clicks:
+		t.Errorf("received %+v, want no bot or other link clicks", click)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestHubUnsubscribesAfterLastSubscriber(t *testing.T) {
+	h, mr, p := newHub(t)
+	ctx := context.Background()
+	linkID := uuid.New()
+
+	first, cancelFirst, err := h.Subscribe(ctx, linkID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	second, cancelSecond, err := h.Subscribe(ctx, linkID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	waitSubscribers(t, mr, linkID, 1) // a single Redis subscription is shared
+
+	cancelFirst()
+	cancelFirst() // cancelling twice is a no-op
+	if _, ok := <-first; ok {
+		t.Error("channel of a cancelled subscription is open")
+	}
+
+	if err := p.Publish(ctx, []model.Analytics{{LinkID: linkID, Device: "desktop"}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := receive(t, second); got.Device != "desktop" {
+		t.Errorf("received %+v, want the published click", got)
+	}
+
+	cancelSecond()
+	waitSubscribers(t, mr, linkID, 0)
+
+	// Subscribing again subscribes to Redis again.
+	_, cancel, err := h.Subscribe(ctx, linkID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer cancel()
+	waitSubscribers(t, mr, linkID, 1)
+}
+
+func TestHubDropsClicksOfSlowSubscribers(t *testing.T) {
+	h, mr, _ := newHub(t)
+	linkID := uuid.New()
+
+	slow, cancelSlow, err := h.Subscribe(context.Background(), linkID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer cancelSlow()
+	fast, cancelFast, err := h.Subscribe(context.Background(), linkID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer cancelFast()
+	waitSubscribers(t, mr, linkID, 1)
+
+	// Deliveries never block on the full buffer of the slow subscriber.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range subscriberBuffer + 10 {
+			h.deliver(&goredis.Message{
+				Channel: channel(linkID),
+				Payload: `{"device":"` + strconv.Itoa(i) + `"}`,
+			})
+			<-fast
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery blocked on a slow subscriber")
+	}
+
+	if n := len(slow); n != subscriberBuffer {
+		t.Fatalf("buffered clicks = %d, want %d", n, subscriberBuffer)
+	}
+	if got := receive(t, slow); got.Device != "0" {
+		t.Errorf("first buffered click = %+v, want the oldest one kept", got)
+	}
+}
+
+func TestHubCloseEndsSubscriptions(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := &redis.Client{Client: goredis.NewClient(&goredis.Options{Addr: mr.Addr()})}
+	defer rdb.Close()
+
+	h := New(rdb)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.Run(ctx)
+		close(done)
+	}()
+
+	clicks, unsubscribe, err := h.Subscribe(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	cancel()
+	<-done
+
+	if _, ok := <-clicks; ok {
+		t.Error("channel open after the hub stopped")
+	}
+	unsubscribe() // no-op after the hub closed the channel
+
+	if _, _, err := h.Subscribe(context.Background(), uuid.New()); err == nil {
+		t.Error("Subscribe() after the hub stopped succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/api/respond"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	apikeysvc "github.com/aliskhannn/url-shortener/internal/service/apikey"
+)
+
+// ownerIDKey is the Gin context key under which the authenticated owner ID is stored.
+const ownerIDKey = "owner_id"
+
+// authenticator defines the interface that AuthMiddleware depends on.
+type authenticator interface {
+	Authenticate(ctx context.Context, token string) (model.APIKey, error)
+}
+
+// AuthMiddleware returns a Gin middleware that authenticates requests by the API key
+// passed in the "Authorization: Bearer <key>" header.
+//
+// On success it stores the key owner ID in the context, see OwnerID.
+// Requests without a valid key are rejected with 401 Unauthorized.
+func AuthMiddleware(auth authenticator) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		token, ok := bearerToken(c.Request)
+		if !ok {
+			respond.Fail(c.Writer, http.StatusUnauthorized, fmt.Errorf("missing api key"))
+			c.Abort()
+			return
+		}
+
+		key, err := auth.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, apikeysvc.ErrInvalidKey) {
+				respond.Fail(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid api key"))
+				c.Abort()
+				return
+			}
+
+			zlog.Logger.Error().Err(err).Msg("failed to authenticate api key")
+			respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+			c.Abort()
+			return
+		}
+
+		c.Set(ownerIDKey, key.OwnerID)
+		c.Next()
+	}
+}
+
+// AdminMiddleware returns a Gin middleware that only lets through requests
+// carrying the configured admin token as a bearer token.
+//
+// If the admin token is not configured, all requests are rejected.
+func AdminMiddleware(adminToken string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		token, ok := bearerToken(c.Request)
+		if !ok || adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			respond.Fail(c.Writer, http.StatusForbidden, fmt.Errorf("forbidden"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// OwnerID returns the ID of the owner authenticated by AuthMiddleware.
+func OwnerID(c *ginext.Context) uuid.UUID {
+	id, _ := c.Get(ownerIDKey)
+	ownerID, _ := id.(uuid.UUID)
+	return ownerID
+}
+
+// bearerToken extracts the token from the "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
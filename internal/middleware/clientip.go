@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+// Forwarding headers telling the client IP, see ParseClientIPHeader.
+const (
+	HeaderForwarded     = "Forwarded" // RFC 7239
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// ParseClientIPHeader returns the canonical name of the forwarding header telling the client
+// IP, or an empty string if no header is set and client IPs are taken from peers.
+func ParseClientIPHeader(name string) (string, error) {
+	switch header := http.CanonicalHeaderKey(strings.TrimSpace(name)); header {
+	case "":
+		return "", nil
+	case HeaderForwarded, HeaderXForwardedFor, http.CanonicalHeaderKey(HeaderXRealIP):
+		return header, nil
+	default:
+		return "", fmt.Errorf("unsupported client ip header %q", name)
+	}
+}
+
+// ParseTrustedProxies parses CIDRs of trusted proxies, where a bare IP stands for itself.
+func ParseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// ClientIPMiddleware returns a Gin middleware that resolves the IP of the client sending
+// the request and stores it as the remote address of the request, keeping the port of the
+// immediate peer, so redirects, analytics and logs all see the same client IP.
+//
+// Only the given forwarding header is honored, and only if the immediate peer is a trusted
+// proxy: other headers may be passed through by the proxy as sent by the client. The client
+// is the last address of the chain not belonging to a trusted proxy. The engine must not
+// resolve client IPs itself, see gin.Engine.ForwardedByClientIP.
+func ClientIPMiddleware(trusted []*net.IPNet, header string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		host, port, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		if ip := clientIP(c.Request.Header, header, net.ParseIP(host), trusted); ip != nil {
+			c.Request.RemoteAddr = net.JoinHostPort(ip.String(), port)
+		}
+
+		c.Next()
+	}
+}
+
+// clientIP returns the IP of the client behind the peer told by the forwarding header
+// of the request, or nil if the peer is not an IP.
+func clientIP(h http.Header, header string, peer net.IP, trusted []*net.IPNet) net.IP {
+	if peer == nil || header == "" || !isTrusted(peer, trusted) {
+		return peer
+	}
+
+	var chain []string
+	switch values := h.Values(header); header {
+	case HeaderForwarded:
+		chain = forwardedFor(values)
+	case HeaderXForwardedFor:
+		chain = splitList(values)
+	default:
+		if len(values) > 0 {
+			chain = []string{strings.TrimSpace(values[len(values)-1])}
+		}
+	}
+
+	// Walk the chain from the nearest hop, stopping at the first untrusted or invalid address.
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseHop(chain[i])
+		if ip == nil {
+			break
+		}
+
+		client = ip
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+
+	return client
+}
+
+// isTrusted reports whether the IP belongs to a trusted proxy.
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitList splits values of a comma-separated list header into trimmed elements.
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			list = append(list, strings.TrimSpace(e))
+		}
+	}
+
+	return list
+}
+
+// forwardedFor returns the for parameters of elements of the Forwarded header,
+// empty for elements without one.
+func forwardedFor(values []string) []string {
+	elements := splitList(values)
+
+	chain := make([]string, 0, len(elements))
+	for _, element := range elements {
+		var node string
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				node = strings.Trim(value, `"`)
+			}
+		}
+
+		chain = append(chain, node)
+	}
+
+	return chain
+}
+
+// parseHop parses an address of a forwarding chain, optionally with a port and with
+// IPv6 addresses in brackets. It returns nil for unknown or obfuscated addresses.
+func parseHop(hop string) net.IP {
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return net.ParseIP(host)
+	}
+
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]"))
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestParseClientIPHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"empty", "", "", false},
+		{"forwarded", "forwarded", HeaderForwarded, false},
+		{"x-forwarded-for", "x-forwarded-for", HeaderXForwardedFor, false},
+		{"x-real-ip", " X-Real-IP ", "X-Real-Ip", false},
+		{"unsupported", "X-Client-IP", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClientIPHeader(tt.header)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseClientIPHeader(%q) = %q, %v, want %q, error %t", tt.header, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	proxy, stranger := net.ParseIP("10.0.0.1"), net.ParseIP("198.51.100.1")
+
+	tests := []struct {
+		name    string
+		header  string
+		peer    net.IP
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:    "untrusted peer",
+			header:  HeaderXForwardedFor,
+			peer:    stranger,
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "no header configured",
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:   "no forwarding header",
+			header: HeaderXForwardedFor,
+			peer:   proxy,
+			want:   "10.0.0.1",
+		},
+		{
+			name:    "x-forwarded-for",
+			header:  HeaderXForwardedFor,
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "x-forwarded-for with spoofed entry",
+			header:  HeaderXForwardedFor,
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, 203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "x-forwarded-for through trusted hops",
+			header:  HeaderXForwardedFor,
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7, 192.168.1.5", "192.168.1.6"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "x-forwarded-for with invalid hop",
+			header:  HeaderXForwardedFor,
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7, unknown, 192.168.1.5"}},
+			want:    "192.168.1.5",
+		},
+		{
+			name:   "other headers ignored",
+			header: HeaderXForwardedFor,
+			peer:   proxy,
+			headers: map[string][]string{
+				"Forwarded":       {"for=1.2.3.4"},
+				"X-Real-Ip":       {"5.6.7.8"},
+				"X-Forwarded-For": {"203.0.113.7"},
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name:    "spoofed forwarded without x-forwarded-for",
+			header:  HeaderXForwardedFor,
+			peer:    proxy,
+			headers: map[string][]string{"Forwarded": {"for=1.2.3.4"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "forwarded",
+			header:  HeaderForwarded,
+			peer:    proxy,
+			headers: map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711";proto=https, for=192.168.1.5`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "forwarded with ipv4 and port",
+			header:  HeaderForwarded,
+			peer:    proxy,
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.7:8080"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "forwarded without for",
+			header:  HeaderForwarded,
+			peer:    proxy,
+			headers: map[string][]string{"Forwarded": {"proto=https"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-real-ip",
+			header:  "X-Real-Ip",
+			peer:    proxy,
+			headers: map[string][]string{"X-Real-Ip": {" 203.0.113.7 "}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:   "nil peer",
+			header: HeaderXForwardedFor,
+			want:   "<nil>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(http.Header(tt.headers), tt.header, tt.peer, trusted); got.String() != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/api/respond"
+	"github.com/aliskhannn/url-shortener/internal/model"
+	workspacerepo "github.com/aliskhannn/url-shortener/internal/repository/workspace"
+	workspacesvc "github.com/aliskhannn/url-shortener/internal/service/workspace"
+)
+
+// WorkspaceHeader is the request header carrying the slug of the workspace a request operates on.
+const WorkspaceHeader = "X-Workspace"
+
+// Gin context keys under which the workspace context is stored.
+const (
+	workspaceKey     = "workspace"
+	workspaceRoleKey = "workspace_role"
+)
+
+// workspaceAuthorizer defines the interface that WorkspaceMiddleware depends on.
+type workspaceAuthorizer interface {
+	Authorize(ctx context.Context, slug string, userID uuid.UUID) (model.Workspace, string, error)
+}
+
+// WorkspaceMiddleware returns a Gin middleware that resolves the workspace from the
+// X-Workspace header and checks that the authenticated owner is a member of it.
+//
+// It must run after AuthMiddleware. On success it stores the workspace and the
+// member's role in the context, see Workspace and WorkspaceRole.
+func WorkspaceMiddleware(auth workspaceAuthorizer) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		slug := c.GetHeader(WorkspaceHeader)
+		if slug == "" {
+			respond.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("missing %s header", WorkspaceHeader))
+			c.Abort()
+			return
+		}
+
+		ws, role, err := auth.Authorize(c.Request.Context(), slug, OwnerID(c))
+		if err != nil {
+			switch {
+			case errors.Is(err, workspacerepo.ErrWorkspaceNotFound):
+				respond.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("workspace not found"))
+			case errors.Is(err, workspacesvc.ErrNotMember):
+				respond.Fail(c.Writer, http.StatusForbidden, fmt.Errorf("not a workspace member"))
+			default:
+				zlog.Logger.Error().Err(err).Str("workspace", slug).Msg("failed to authorize workspace access")
+				respond.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(workspaceKey, ws)
+		c.Set(workspaceRoleKey, role)
+		c.Next()
+	}
+}
+
+// RequireRole returns a Gin middleware that rejects requests of workspace members
+// whose role grants fewer privileges than the required one.
+//
+// It must run after WorkspaceMiddleware.
+func RequireRole(required string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		if !model.RoleAllows(WorkspaceRole(c), required) {
+			respond.Fail(c.Writer, http.StatusForbidden, fmt.Errorf("%s role required", required))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Workspace returns the workspace resolved by WorkspaceMiddleware.
+func Workspace(c *ginext.Context) model.Workspace {
+	v, _ := c.Get(workspaceKey)
+	ws, _ := v.(model.Workspace)
+	return ws
+}
+
+// LinkOwner returns the owner whose links the request may access in the workspace resolved
+// by WorkspaceMiddleware, or nil if it may access links of all members.
+func LinkOwner(c *ginext.Context) *uuid.UUID {
+	if !Workspace(c).OwnerScoped {
+		return nil
+	}
+
+	ownerID := OwnerID(c)
+	return &ownerID
+}
+
+// WorkspaceRole returns the role of the authenticated owner in the workspace resolved by WorkspaceMiddleware.
+func WorkspaceRole(c *ginext.Context) string {
+	return c.GetString(workspaceRoleKey)
+}
@@ -6,14 +6,150 @@ import (
 	"github.com/google/uuid"
 )
 
+// SourceQR marks visits from scans of QR codes generated for the link.
+const SourceQR = "qr"
+
 // Analytics represents a single visit to a shortened link.
 type Analytics struct {
-	ID        uuid.UUID `json:"id"`         // unique identifier
-	Alias     string    `json:"alias"`      // short alias
-	UserAgent string    `json:"user_agent"` // raw user agent string
-	Device    string    `json:"device"`     // device type (desktop, mobile, tablet, bot)
-	OS        string    `json:"os"`         // operating system
-	Browser   string    `json:"browser"`    // browser name
-	IP        string    `json:"ip"`         // client ip address
-	CreatedAt time.Time `json:"created_at"` // timestamp of the visit
+	ID             uuid.UUID `json:"id"`              // unique identifier
+	LinkID         uuid.UUID `json:"link_id"`         // identifier of the visited link
+	WorkspaceID    uuid.UUID `json:"workspace_id"`    // workspace of the visited link
+	Alias          string    `json:"alias"`           // short alias
+	UserAgent      string    `json:"user_agent"`      // raw user agent string
+	Device         string    `json:"device"`          // device type (desktop, mobile, tablet, bot)
+	IsBot          bool      `json:"is_bot"`          // request classified as a bot, crawler or link preview fetcher
+	BotReason      string    `json:"bot_reason"`      // why the request was classified as a bot, see botdetect
+	OS             string    `json:"os"`              // operating system
+	OSFamily       string    `json:"os_family"`       // normalized OS family, e.g. OSFamilyIOS, empty if unknown
+	Browser        string    `json:"browser"`         // browser name
+	IP             string    `json:"ip"`              // client ip address, anonymized by PrivacyMode before it is stored
+	IPHash         string    `json:"ip_hash"`         // salted hash of the ip stored instead of it with PrivacyModeHash
+	VisitorID      string    `json:"visitor_id"`      // salted hash of the IP and user agent, see visitor.Store
+	Source         string    `json:"source"`          // traffic source marker, e.g. SourceQR, empty for direct visits
+	Referrer       string    `json:"referrer"`        // raw Referer header
+	ReferrerDomain string    `json:"referrer_domain"` // lowercased referring host without www.
+	UTMSource      string    `json:"utm_source"`      // utm_source query parameter
+	UTMMedium      string    `json:"utm_medium"`      // utm_medium query parameter
+	UTMCampaign    string    `json:"utm_campaign"`    // utm_campaign query parameter
+	UTMTerm        string    `json:"utm_term"`        // utm_term query parameter
+	UTMContent     string    `json:"utm_content"`     // utm_content query parameter
+	Language       string    `json:"language"`        // preferred locale from Accept-Language, e.g. en-US
+	Country        string    `json:"country"`         // ISO country code resolved from the ip
+	Region         string    `json:"region"`          // region resolved from the ip
+	City           string    `json:"city"`            // city resolved from the ip
+	ASN            uint      `json:"asn"`             // autonomous system number resolved from the ip
+	ASOrg          string    `json:"as_org"`          // autonomous system organization resolved from the ip
+	Rule           string    `json:"rule"`            // label of the matched redirect rule, empty for the default URL
+	Variant        string    `json:"variant"`         // name of the assigned A/B variant, empty if none
+	CreatedAt      time.Time `json:"created_at"`      // timestamp of the visit
+
+	PrivacyMode string `json:"privacy_mode,omitempty"` // how the ip is stored, see PrivacyModeFull
+	DoNotTrack  bool   `json:"do_not_track,omitempty"` // visitor sent DNT or Sec-GPC, personal fields are not stored
+}
+
+// LiveClick is a click streamed to real-time subscribers of its link.
+type LiveClick struct {
+	Time     time.Time `json:"time"`     // timestamp of the visit
+	Device   string    `json:"device"`   // device type
+	Country  string    `json:"country"`  // ISO country code, empty if unknown
+	Referrer string    `json:"referrer"` // referring domain, empty for direct visits
+}
+
+// Dimension names an analytics attribute clicks can be grouped by.
+type Dimension string
+
+// Dimensions supported by analytics breakdowns.
+const (
+	DimensionReferrer Dimension = "referrer" // referring domain
+	DimensionCampaign Dimension = "campaign" // utm_campaign
+	DimensionLanguage Dimension = "language" // preferred locale
+	DimensionCountry  Dimension = "country"  // ISO country code
+	DimensionCity     Dimension = "city"     // city with its country code, e.g. "Berlin, DE"
+	DimensionRule     Dimension = "rule"     // matched redirect rule
+	DimensionVariant  Dimension = "variant"  // assigned A/B variant
+	DimensionDevice   Dimension = "device"   // device type: desktop, mobile or bot
+	DimensionOS       Dimension = "os"       // OS family
+	DimensionBrowser  Dimension = "browser"  // browser name
+	DimensionTraffic  Dimension = "traffic"  // bot or human
+)
+
+// DimensionCount is the number of clicks with a given value of a dimension.
+type DimensionCount struct {
+	Value  string `json:"value"`  // dimension value
+	Clicks int    `json:"clicks"` // number of clicks
+}
+
+// ClickFilter selects the clicks analytics are computed from: clicks at or after From
+// and before To, where a zero bound leaves that side open, of humans only unless
+// IncludeBots is set.
+type ClickFilter struct {
+	From        time.Time
+	To          time.Time
+	IncludeBots bool
+}
+
+// Granularity is the size of time series buckets.
+type Granularity string
+
+// Granularities supported by analytics time series, named after date_trunc fields.
+const (
+	GranularityMinute Granularity = "minute"
+	GranularityHour   Granularity = "hour"
+	GranularityDay    Granularity = "day"
+	GranularityWeek   Granularity = "week" // weeks start on Monday
+	GranularityMonth  Granularity = "month"
+)
+
+// IsValid reports whether the granularity is supported.
+func (g Granularity) IsValid() bool {
+	switch g {
+	case GranularityMinute, GranularityHour, GranularityDay, GranularityWeek, GranularityMonth:
+		return true
+	}
+
+	return false
+}
+
+// Truncate returns the start of the bucket containing t, computed on the wall clock
+// of t like date_trunc does.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	h, mi, _ := t.Clock()
+
+	switch g {
+	case GranularityMinute:
+		return time.Date(y, mo, d, h, mi, 0, 0, t.Location())
+	case GranularityHour:
+		return time.Date(y, mo, d, h, 0, 0, 0, t.Location())
+	case GranularityWeek:
+		return time.Date(y, mo, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case GranularityMonth:
+		return time.Date(y, mo, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, mo, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// Next returns the start of the bucket following the one starting at t.
+func (g Granularity) Next(t time.Time) time.Time {
+	switch g {
+	case GranularityMinute:
+		return t.Add(time.Minute)
+	case GranularityHour:
+		return t.Add(time.Hour)
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// TimeBucket is the number of clicks within a time series bucket.
+type TimeBucket struct {
+	Time   time.Time `json:"time"`   // start of the bucket
+	Clicks int       `json:"clicks"` // number of clicks
+
+	UniqueVisitors int `json:"unique_visitors"` // number of distinct visitors
 }
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey represents an API key used to authenticate requests.
+// Only a hash of the key is stored, the key itself is shown once on creation.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`                     // unique identifier
+	OwnerID    uuid.UUID  `json:"owner_id"`               // identifier of the key owner
+	Name       string     `json:"name"`                   // human-readable key name
+	Prefix     string     `json:"prefix"`                 // first characters of the key, used to tell keys apart
+	Hash       string     `json:"-"`                      // sha256 hex digest of the key
+	CreatedAt  time.Time  `json:"created_at"`             // creation timestamp
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // timestamp of the last successful authentication
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`   // revocation timestamp, nil if the key is active
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Domain verification DNS record settings.
+const (
+	DomainVerificationRecordPrefix = "_shortener-verify." // prefix of the TXT record name
+	DomainVerificationValuePrefix  = "shortener-verify="  // prefix of the TXT record value
+)
+
+// Domain represents a custom branded domain serving short links of a workspace.
+type Domain struct {
+	ID                uuid.UUID  `json:"id"`                    // unique identifier
+	WorkspaceID       uuid.UUID  `json:"workspace_id"`          // workspace the domain belongs to
+	Hostname          string     `json:"hostname"`              // lowercase hostname without port
+	VerificationToken string     `json:"verification_token"`    // token expected in the verification TXT record
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"` // verification timestamp, nil until ownership is proven
+	CreatedAt         time.Time  `json:"created_at"`            // registration timestamp
+}
+
+// VerificationRecord returns the name of the TXT record proving domain ownership.
+func (d Domain) VerificationRecord() string {
+	return DomainVerificationRecordPrefix + d.Hostname
+}
+
+// VerificationValue returns the value of the TXT record proving domain ownership.
+func (d Domain) VerificationValue() string {
+	return DomainVerificationValuePrefix + d.VerificationToken
+}
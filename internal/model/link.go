@@ -1,6 +1,7 @@
 package model
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,8 +9,141 @@ import (
 
 // Link represents a shortened URL entry.
 type Link struct {
-	ID        uuid.UUID `json:"id"`         // unique identifier
-	URL       string    `json:"url"`        // original url
-	Alias     string    `json:"alias"`      // short alias
-	CreatedAt time.Time `json:"created_at"` // creation timestamp
+	ID               uuid.UUID      `json:"id"`                        // unique identifier
+	URL              string         `json:"url"`                       // original url
+	Alias            string         `json:"alias"`                     // short alias
+	WorkspaceID      uuid.UUID      `json:"workspace_id"`              // workspace the link belongs to
+	Domain           string         `json:"domain"`                    // custom domain hostname, empty for the default host
+	OwnerID          uuid.UUID      `json:"owner_id"`                  // identifier of the API key owner who created the link
+	Enabled          bool           `json:"enabled"`                   // disabled links do not redirect
+	RedirectCode     int            `json:"redirect_code"`             // HTTP status of redirects: 301, 302, 307 or 308
+	RedirectMode     string         `json:"redirect_mode"`             // RedirectModeHTTP, RedirectModeMeta or RedirectModeJS
+	TrackingPixels   []string       `json:"tracking_pixels,omitempty"` // image URLs requested by the JS redirect page before leaving
+	QueryPassthrough string         `json:"query_passthrough"`         // QueryPassthroughOff, QueryPassthroughMerge or QueryPassthroughOverride
+	PathPassthrough  bool           `json:"path_passthrough"`          // append the path following the alias to the destination
+	Rules            []RedirectRule `json:"rules,omitempty"`           // ordered redirect rules, the first matching one overrides URL
+	Variants         []Variant      `json:"variants,omitempty"`        // weighted A/B destinations used instead of URL when no rule matches
+	PrivacyMode      string         `json:"privacy_mode,omitempty"`    // how client IPs of clicks are stored, empty for the global mode
+	ExpiresAt        *time.Time     `json:"expires_at,omitempty"`      // expiration timestamp, nil if the link never expires
+	ArchivedAt       *time.Time     `json:"archived_at,omitempty"`     // set by the sweeper when an expired link is archived
+	CreatedAt        time.Time      `json:"created_at"`                // creation timestamp
+	UpdatedAt        time.Time      `json:"updated_at"`                // last modification timestamp
+}
+
+// Redirect modes of a link.
+const (
+	RedirectModeHTTP = "http" // HTTP redirect with the link's redirect code
+	RedirectModeMeta = "meta" // HTML page with a meta refresh
+	RedirectModeJS   = "js"   // HTML page redirecting with JavaScript after firing tracking pixels
+)
+
+// Query passthrough modes of a link.
+const (
+	QueryPassthroughOff      = "off"      // incoming query parameters are dropped
+	QueryPassthroughMerge    = "merge"    // incoming parameters are added, destination parameters win on conflict
+	QueryPassthroughOverride = "override" // incoming parameters are added, replacing destination parameters on conflict
+)
+
+// Privacy modes telling how client IPs of clicks are stored.
+const (
+	PrivacyModeFull     = "full"     // the IP as is
+	PrivacyModeTruncate = "truncate" // the IPv4 /24 or IPv6 /48 network of the IP
+	PrivacyModeHash     = "hash"     // only a hash of the IP salted with a salt rotating daily
+)
+
+// IsValidRedirectCode reports whether code is a redirect status supported by links.
+func IsValidRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// RedirectStatus returns the HTTP status used to redirect to the link,
+// defaulting to 302 Found for links cached before redirect codes existed.
+func (l Link) RedirectStatus() int {
+	if !IsValidRedirectCode(l.RedirectCode) {
+		return http.StatusFound
+	}
+
+	return l.RedirectCode
+}
+
+// LinkKey identifies a link, since aliases are unique only per workspace and domain.
+type LinkKey struct {
+	WorkspaceID uuid.UUID  // workspace of the link
+	Domain      string     // custom domain hostname, empty for the default host
+	Alias       string     // short alias
+	OwnerID     *uuid.UUID // creator of the link, nil for links of any owner
+}
+
+// Key returns the key identifying the link.
+func (l Link) Key() LinkKey {
+	return LinkKey{WorkspaceID: l.WorkspaceID, Domain: l.Domain, Alias: l.Alias}
+}
+
+// LinkUpdate describes a partial update of a link. Nil fields are left unchanged.
+type LinkUpdate struct {
+	URL              *string         // new original url
+	Enabled          *bool           // new enabled state
+	RedirectCode     *int            // new redirect status
+	RedirectMode     *string         // new redirect mode
+	TrackingPixels   *[]string       // new tracking pixels, an empty slice removes them
+	QueryPassthrough *string         // new query passthrough mode
+	PathPassthrough  *bool           // new path passthrough state
+	Rules            *[]RedirectRule // new redirect rules, an empty slice removes them
+	Variants         *[]Variant      // new A/B variants, an empty slice removes them
+	PrivacyMode      *string         // new privacy mode, an empty string falls back to the global mode
+}
+
+// IsExpired reports whether the link is no longer valid at the given moment.
+func (l Link) IsExpired(now time.Time) bool {
+	if l.ArchivedAt != nil {
+		return true
+	}
+
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// Sort orders supported when listing links.
+const (
+	LinkSortCreatedAt = "created_at"
+	LinkSortClicks    = "clicks"
+)
+
+// LinkFilter describes criteria for listing links. Zero-valued fields are ignored.
+type LinkFilter struct {
+	WorkspaceID uuid.UUID  // workspace of the links, always applied
+	Domain      *string    // custom domain hostname, empty string for the default host
+	OwnerID     *uuid.UUID // creator of the links
+	Host        string     // destination host, matched case-insensitively
+	AliasPrefix string     // alias prefix
+	CreatedFrom *time.Time // inclusive lower bound of creation time
+	CreatedTo   *time.Time // exclusive upper bound of creation time
+	Query       string     // free-text match on destination url
+	Sort        string     // LinkSortCreatedAt or LinkSortClicks
+	Desc        bool       // sort in descending order
+	Limit       int        // page size
+}
+
+// LinkCursor points at the last link of a page when listing links.
+type LinkCursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	Clicks    int64     `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// LinkListItem represents a link together with its click count.
+type LinkListItem struct {
+	Link
+	Clicks int64 `json:"clicks"` // total number of human clicks
+}
+
+// LinkPage represents a single page of listed links.
+type LinkPage struct {
+	Items      []LinkListItem `json:"items"`                 // links on this page
+	NextCursor string         `json:"next_cursor,omitempty"` // cursor of the next page, empty if this page is the last one
 }
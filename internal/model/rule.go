@@ -0,0 +1,110 @@
+package model
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OS families recognized by redirect rules.
+const (
+	OSFamilyIOS      = "ios"
+	OSFamilyAndroid  = "android"
+	OSFamilyWindows  = "windows"
+	OSFamilyMacOS    = "macos"
+	OSFamilyLinux    = "linux"
+	OSFamilyChromeOS = "chromeos"
+)
+
+// RedirectRule sends visitors matching all of its non-empty criteria to its own URL.
+// A criterion matches if any of its values matches the visitor.
+type RedirectRule struct {
+	Name     string   `json:"name,omitempty"`     // label recorded in analytics, defaults to "rule-<position>"
+	Device   []string `json:"device,omitempty"`   // device types: desktop, mobile, bot
+	OS       []string `json:"os,omitempty"`       // OS families, e.g. OSFamilyIOS
+	Country  []string `json:"country,omitempty"`  // ISO country codes resolved from the ip
+	Language []string `json:"language,omitempty"` // locales or language prefixes, e.g. fr or pt-BR
+	URL      string   `json:"url"`                // destination of matching visitors
+}
+
+// RuleSubject describes a visitor redirect rules are evaluated against.
+type RuleSubject struct {
+	Device   string // device type
+	OSFamily string // OS family
+	Country  string // ISO country code
+	Language string // preferred locale
+}
+
+// Matches reports whether the visitor matches the rule.
+func (r RedirectRule) Matches(s RuleSubject) bool {
+	if len(r.Device) > 0 && !containsFold(r.Device, s.Device) {
+		return false
+	}
+	if len(r.OS) > 0 && !containsFold(r.OS, s.OSFamily) {
+		return false
+	}
+	if len(r.Country) > 0 && !containsFold(r.Country, s.Country) {
+		return false
+	}
+	if len(r.Language) > 0 && !slices.ContainsFunc(r.Language, func(l string) bool {
+		return matchLanguage(l, s.Language)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// NeedsCountry reports whether evaluating the rule requires the visitor's country.
+func (r RedirectRule) NeedsCountry() bool {
+	return len(r.Country) > 0
+}
+
+// Label returns the name of the rule at the given zero-based position in the rule list.
+func (r RedirectRule) Label(position int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	return "rule-" + strconv.Itoa(position+1)
+}
+
+// containsFold reports whether values contain v, ignoring case. Empty v never matches.
+func containsFold(values []string, v string) bool {
+	if v == "" {
+		return false
+	}
+
+	return slices.ContainsFunc(values, func(s string) bool { return strings.EqualFold(s, v) })
+}
+
+// matchLanguage reports whether the locale matches the rule language: either exactly
+// or, for rule languages without a region, by the language prefix (fr matches fr-CA).
+func matchLanguage(rule, locale string) bool {
+	if locale == "" {
+		return false
+	}
+	if strings.EqualFold(rule, locale) {
+		return true
+	}
+
+	lang, _, _ := strings.Cut(locale, "-")
+	return !strings.Contains(rule, "-") && strings.EqualFold(rule, lang)
+}
+
+// MatchRule returns the position of the first rule of the link matching the visitor,
+// or -1 if none does and the visitor should be sent to the link's URL.
+func (l Link) MatchRule(s RuleSubject) int {
+	for i, r := range l.Rules {
+		if r.Matches(s) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// RulesNeedCountry reports whether evaluating the link's rules requires the visitor's country.
+func (l Link) RulesNeedCountry() bool {
+	return slices.ContainsFunc(l.Rules, RedirectRule.NeedsCountry)
+}
@@ -0,0 +1,56 @@
+package model
+
+import "testing"
+
+func TestMatchRule(t *testing.T) {
+	link := Link{Rules: []RedirectRule{
+		{Name: "ios", OS: []string{OSFamilyIOS}, URL: "https://apps.apple.com/app"},
+		{Device: []string{"mobile"}, Country: []string{"DE", "AT"}, URL: "https://example.de/m"},
+		{Language: []string{"fr", "pt-BR"}, URL: "https://example.com/fr"},
+	}}
+
+	tests := []struct {
+		name    string
+		subject RuleSubject
+		want    int
+	}{
+		{"no match", RuleSubject{Device: "desktop", OSFamily: OSFamilyWindows, Country: "US", Language: "en-US"}, -1},
+		{"empty subject", RuleSubject{}, -1},
+		{"first matching rule wins", RuleSubject{Device: "mobile", OSFamily: OSFamilyIOS, Country: "DE"}, 0},
+		{"all criteria match", RuleSubject{Device: "mobile", OSFamily: OSFamilyAndroid, Country: "AT"}, 1},
+		{"criteria ignore case", RuleSubject{Device: "Mobile", OSFamily: OSFamilyAndroid, Country: "de"}, 1},
+		{"one criterion fails", RuleSubject{Device: "desktop", OSFamily: OSFamilyAndroid, Country: "DE"}, -1},
+		{"unknown country", RuleSubject{Device: "mobile", OSFamily: OSFamilyAndroid}, -1},
+		{"language prefix", RuleSubject{Language: "fr-CA"}, 2},
+		{"exact locale", RuleSubject{Language: "pt-BR"}, 2},
+		{"other region of locale", RuleSubject{Language: "pt-PT"}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := link.MatchRule(tt.subject); got != tt.want {
+				t.Errorf("MatchRule() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     RedirectRule
+		position int
+		want     string
+	}{
+		{"named", RedirectRule{Name: "ios"}, 0, "ios"},
+		{"unnamed", RedirectRule{}, 2, "rule-3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Label(tt.position); got != tt.want {
+				t.Errorf("Label() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
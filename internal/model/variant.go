@@ -0,0 +1,49 @@
+package model
+
+// Variant is one of weighted destinations of a link used for A/B testing.
+type Variant struct {
+	Name   string `json:"name"`   // label recorded in analytics
+	URL    string `json:"url"`    // destination of visitors assigned to the variant
+	Weight int    `json:"weight"` // relative share of visitors
+}
+
+// PickVariant returns the position of the variant of the link for a visitor bucket,
+// distributing buckets proportionally to the variant weights, or -1 if the link
+// has no variants with a positive weight.
+func (l Link) PickVariant(bucket uint64) int {
+	var total uint64
+	for _, v := range l.Variants {
+		if v.Weight > 0 {
+			total += uint64(v.Weight)
+		}
+	}
+
+	if total == 0 {
+		return -1
+	}
+
+	n := bucket % total
+	for i, v := range l.Variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if n < uint64(v.Weight) {
+			return i
+		}
+		n -= uint64(v.Weight)
+	}
+
+	return -1
+}
+
+// VariantByName returns the position of the variant with the given name, or -1 if
+// the link has no such variant with a positive weight.
+func (l Link) VariantByName(name string) int {
+	for i, v := range l.Variants {
+		if v.Name == name && v.Weight > 0 {
+			return i
+		}
+	}
+
+	return -1
+}
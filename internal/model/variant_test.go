@@ -0,0 +1,69 @@
+package model
+
+import "testing"
+
+func TestPickVariant(t *testing.T) {
+	weighted := Link{Variants: []Variant{
+		{Name: "a", Weight: 1},
+		{Name: "off", Weight: 0},
+		{Name: "b", Weight: 3},
+	}}
+
+	tests := []struct {
+		name   string
+		link   Link
+		bucket uint64
+		want   int
+	}{
+		{"no variants", Link{}, 5, -1},
+		{"no positive weights", Link{Variants: []Variant{{Name: "a"}, {Name: "b", Weight: -1}}}, 5, -1},
+		{"first share", weighted, 0, 0},
+		{"zero weight skipped", weighted, 1, 2},
+		{"last bucket of share", weighted, 3, 2},
+		{"buckets wrap around", weighted, 4, 0},
+		{"large bucket", weighted, 1<<64 - 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.link.PickVariant(tt.bucket); got != tt.want {
+				t.Errorf("PickVariant(%d) = %d, want %d", tt.bucket, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickVariantDistribution(t *testing.T) {
+	link := Link{Variants: []Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 3}}}
+
+	counts := make([]int, len(link.Variants))
+	for bucket := uint64(0); bucket < 400; bucket++ {
+		counts[link.PickVariant(bucket)]++
+	}
+
+	if counts[0] != 100 || counts[1] != 300 {
+		t.Errorf("PickVariant() distribution = %v, want [100 300]", counts)
+	}
+}
+
+func TestVariantByName(t *testing.T) {
+	link := Link{Variants: []Variant{{Name: "a", Weight: 1}, {Name: "off"}}}
+
+	tests := []struct {
+		name    string
+		variant string
+		want    int
+	}{
+		{"known", "a", 0},
+		{"zero weight", "off", -1},
+		{"unknown", "c", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := link.VariantByName(tt.variant); got != tt.want {
+				t.Errorf("VariantByName(%q) = %d, want %d", tt.variant, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Workspace member roles.
+const (
+	RoleOwner  = "owner"  // manages members and everything editors can do
+	RoleEditor = "editor" // creates and modifies links
+	RoleViewer = "viewer" // reads links and analytics
+)
+
+// DefaultWorkspaceSlug is the slug of the workspace created by migrations,
+// which serves redirects on the shared /s/:alias route.
+const DefaultWorkspaceSlug = "default"
+
+// Workspace represents a tenant owning an isolated namespace of link aliases.
+//
+// Members of an owner-scoped workspace see and manage only the links they created,
+// which keeps links of the unrelated API key owners sharing the default workspace apart.
+type Workspace struct {
+	ID          uuid.UUID `json:"id"`           // unique identifier
+	Slug        string    `json:"slug"`         // unique url-friendly name
+	Name        string    `json:"name"`         // display name
+	OwnerScoped bool      `json:"owner_scoped"` // links are visible only to their creators
+	CreatedAt   time.Time `json:"created_at"`   // creation timestamp
+}
+
+// WorkspaceMember represents membership of a user in a workspace.
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID `json:"workspace_id"` // workspace identifier
+	UserID      uuid.UUID `json:"user_id"`      // owner id of the member's API keys
+	Role        string    `json:"role"`         // RoleOwner, RoleEditor or RoleViewer
+	CreatedAt   time.Time `json:"created_at"`   // timestamp of joining the workspace
+}
+
+// UserWorkspace represents a workspace together with the role of the user in it.
+type UserWorkspace struct {
+	Workspace
+	Role string `json:"role"` // role of the user in the workspace
+}
+
+// roleRanks orders roles by privileges.
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// IsValidRole reports whether role is a known workspace role.
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows reports whether role grants at least the privileges of the required role.
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required] && roleRanks[role] > 0
+}
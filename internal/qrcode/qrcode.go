@@ -0,0 +1,305 @@
+// Package qrcode renders QR codes as PNG and SVG images.
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // register GIF logos
+	_ "image/jpeg" // register JPEG logos
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"rsc.io/qr"
+)
+
+// Limits of rendering options.
+const (
+	MinSize      = 64      // minimal image side in pixels
+	MaxSize      = 2048    // maximal image side in pixels
+	MaxMargin    = 16      // maximal quiet zone in modules
+	MaxLogoBytes = 1 << 20 // maximal size of a downloaded logo
+	MaxLogoSide  = 1024    // maximal logo side in pixels, bounding memory of decoded logos
+
+	logoRatio = 0.2 // logo side relative to the symbol side
+)
+
+var (
+	ErrTooSmall     = errors.New("image size too small for the qr code")
+	ErrInvalidLevel = errors.New("invalid error correction level")
+	ErrInvalidColor = errors.New("invalid color")
+	ErrInvalidLogo  = errors.New("invalid logo")
+)
+
+// Options describe how a QR code is rendered.
+type Options struct {
+	Size       int         // image side in pixels
+	Level      qr.Level    // error correction level
+	Margin     int         // quiet zone around the symbol in modules
+	Foreground color.RGBA  // color of dark modules
+	Background color.RGBA  // color of light modules and the quiet zone
+	Logo       image.Image // optional logo drawn in the center, nil for none
+}
+
+// ParseLevel parses an error correction level: L, M, Q or H.
+func ParseLevel(s string) (qr.Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	default:
+		return 0, ErrInvalidLevel
+	}
+}
+
+// ParseColor parses a hex color in the rgb or rrggbb form, with an optional leading #.
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+
+	if len(s) != 6 {
+		return color.RGBA{}, ErrInvalidColor
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrInvalidColor
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// maxLogoRedirects is the number of redirects followed when downloading a logo.
+const maxLogoRedirects = 5
+
+// AllowedLogoURL reports whether the logo may be downloaded from u: an http(s) URL on one
+// of the lowercase hosts.
+func AllowedLogoURL(u *url.URL, hosts []string) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && slices.Contains(hosts, strings.ToLower(u.Hostname()))
+}
+
+// NewLogoClient creates an HTTP client downloading logos from the hosts, which follows
+// redirects only to URLs allowed by AllowedLogoURL.
+func NewLogoClient(hosts []string) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxLogoRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxLogoRedirects)
+			}
+			if !AllowedLogoURL(req.URL, hosts) {
+				return fmt.Errorf("redirect to disallowed host %q", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
+
+// FetchLogo downloads and decodes a PNG, JPEG or GIF logo of at most MaxLogoBytes bytes
+// and MaxLogoSide pixels per side. Use a client created by NewLogoClient.
+func FetchLogo(ctx context.Context, client *http.Client, url string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogo, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogo, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrInvalidLogo, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, MaxLogoBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogo, err)
+	}
+	if len(b) > MaxLogoBytes {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidLogo, MaxLogoBytes)
+	}
+
+	// Small files may decode to huge images, so dimensions are checked before decoding.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogo, err)
+	}
+	if cfg.Width > MaxLogoSide || cfg.Height > MaxLogoSide {
+		return nil, fmt.Errorf("%w: %dx%d pixels exceed %d", ErrInvalidLogo, cfg.Width, cfg.Height, MaxLogoSide)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogo, err)
+	}
+
+	return img, nil
+}
+
+// PNG renders text as a QR code PNG image.
+func PNG(text string, opts Options) ([]byte, error) {
+	code, l, err := encode(text, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+
+	fg := image.NewUniform(opts.Foreground)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				draw.Draw(img, l.module(x, y), fg, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if opts.Logo != nil {
+		box := l.logoBox()
+		draw.Draw(img, box, image.NewUniform(opts.Background), image.Point{}, draw.Src)
+		drawScaled(img, box.Inset(l.scale), opts.Logo)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG renders text as a QR code SVG image.
+func SVG(text string, opts Options) ([]byte, error) {
+	code, l, err := encode(text, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, opts.Size, opts.Size,
+	)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hex(opts.Background))
+
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hex(opts.Foreground))
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				r := l.module(x, y)
+				fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", r.Min.X, r.Min.Y, l.scale, l.scale, l.scale)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if opts.Logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, opts.Logo); err != nil {
+			return nil, fmt.Errorf("encode logo: %w", err)
+		}
+
+		box := l.logoBox()
+		inner := box.Inset(l.scale)
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+			box.Min.X, box.Min.Y, box.Dx(), box.Dy(), hex(opts.Background))
+		fmt.Fprintf(&buf,
+			`<image x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`,
+			inner.Min.X, inner.Min.Y, inner.Dx(), inner.Dy(), base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	buf.WriteString(`</svg>`)
+
+	return buf.Bytes(), nil
+}
+
+// layout places modules of a symbol within the image.
+type layout struct {
+	size   int // symbol side in modules
+	scale  int // module side in pixels
+	offset int // position of the first module in pixels
+}
+
+// module returns the pixel rectangle of the module at x, y.
+func (l layout) module(x, y int) image.Rectangle {
+	return image.Rect(
+		l.offset+x*l.scale, l.offset+y*l.scale,
+		l.offset+(x+1)*l.scale, l.offset+(y+1)*l.scale,
+	)
+}
+
+// logoBox returns the pixel rectangle in the center of the symbol reserved for the logo.
+func (l layout) logoBox() image.Rectangle {
+	side := int(float64(l.size)*logoRatio) * l.scale
+	minXY := l.offset + (l.size*l.scale-side)/2
+	return image.Rect(minXY, minXY, minXY+side, minXY+side)
+}
+
+// encode encodes text and computes the layout of the symbol with integer module sizes,
+// centered in the image so that leftover pixels extend the quiet zone.
+func encode(text string, opts Options) (*qr.Code, layout, error) {
+	code, err := qr.Encode(text, opts.Level)
+	if err != nil {
+		return nil, layout{}, fmt.Errorf("encode qr code: %w", err)
+	}
+
+	scale := opts.Size / (code.Size + 2*opts.Margin)
+	if scale < 1 {
+		return nil, layout{}, ErrTooSmall
+	}
+
+	return code, layout{size: code.Size, scale: scale, offset: (opts.Size - code.Size*scale) / 2}, nil
+}
+
+// drawScaled draws src into dst scaled to fit r with nearest-neighbor sampling,
+// preserving the aspect ratio and blending transparent pixels over dst.
+func drawScaled(dst draw.Image, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() || r.Empty() {
+		return
+	}
+
+	// Fit the logo into r preserving its aspect ratio.
+	w, h := r.Dx(), r.Dy()
+	if sb.Dx()*h > sb.Dy()*w {
+		h = sb.Dy() * w / sb.Dx()
+	} else {
+		w = sb.Dx() * h / sb.Dy()
+	}
+	r = image.Rect(0, 0, w, h).Add(r.Min.Add(image.Pt((r.Dx()-w)/2, (r.Dy()-h)/2)))
+
+	scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			scaled.Set(x, y, src.At(sb.Min.X+x*sb.Dx()/w, sb.Min.Y+y*sb.Dy()/h))
+		}
+	}
+
+	draw.Draw(dst, r, scaled, image.Point{}, draw.Over)
+}
+
+// hex formats c as a #rrggbb color.
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
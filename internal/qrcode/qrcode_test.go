@@ -0,0 +1,136 @@
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAllowedLogoURL(t *testing.T) {
+	hosts := []string{"cdn.example.com"}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://cdn.example.com/logo.png", true},
+		{"http://cdn.example.com:8080/logo.png", true},
+		{"https://CDN.Example.com/logo.png", true},
+		{"https://evil.com/logo.png", false},
+		{"https://cdn.example.com.evil.com/logo.png", false},
+		{"https://evil.com/?u=https://cdn.example.com/logo.png", false},
+		{"ftp://cdn.example.com/logo.png", false},
+		{"file:///etc/passwd", false},
+		{"/logo.png", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := AllowedLogoURL(u, hosts); got != tt.want {
+				t.Errorf("AllowedLogoURL(%q) = %t, want %t", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+// pngOf encodes a blank PNG image of the given size.
+func pngOf(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestFetchLogo(t *testing.T) {
+	logo := pngOf(t, 32, 16)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(logo)
+	})
+	mux.HandleFunc("/max.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(pngOf(t, MaxLogoSide, MaxLogoSide))
+	})
+	mux.HandleFunc("/wide.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(pngOf(t, MaxLogoSide+1, 1))
+	})
+	mux.HandleFunc("/tall.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(pngOf(t, 1, MaxLogoSide+1))
+	})
+	mux.HandleFunc("/padded.png", func(w http.ResponseWriter, _ *http.Request) {
+		// A valid image followed by padding, decodable if the body were truncated.
+		w.Write(append(bytes.Clone(logo), make([]byte, MaxLogoBytes)...))
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("not an image"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/logo.png", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The server is reachable as both 127.0.0.1 and localhost, only the former is allowed.
+	away := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	mux.HandleFunc("/redirect-away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, away+"/logo.png", http.StatusFound)
+	})
+
+	client := NewLogoClient([]string{"127.0.0.1"})
+
+	tests := []struct {
+		name     string
+		path     string
+		wantSize image.Point
+		wantErr  bool
+	}{
+		{name: "logo", path: "/logo.png", wantSize: image.Pt(32, 16)},
+		{name: "largest logo", path: "/max.png", wantSize: image.Pt(MaxLogoSide, MaxLogoSide)},
+		{name: "redirect to allowed host", path: "/redirect", wantSize: image.Pt(32, 16)},
+		{name: "too wide", path: "/wide.png", wantErr: true},
+		{name: "too tall", path: "/tall.png", wantErr: true},
+		{name: "body over limit", path: "/padded.png", wantErr: true},
+		{name: "not an image", path: "/text", wantErr: true},
+		{name: "not found", path: "/missing.png", wantErr: true},
+		{name: "redirect to disallowed host", path: "/redirect-away", wantErr: true},
+		{name: "redirect loop", path: "/loop", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := FetchLogo(context.Background(), client, srv.URL+tt.path)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidLogo) {
+					t.Fatalf("FetchLogo() error = %v, want %v", err, ErrInvalidLogo)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("FetchLogo() error = %v", err)
+			}
+			if got := img.Bounds().Size(); got != tt.wantSize {
+				t.Errorf("FetchLogo() size = %v, want %v", got, tt.wantSize)
+			}
+		})
+	}
+}
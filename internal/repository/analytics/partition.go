@@ -0,0 +1,228 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Monthly partitions of the analytics table are named analytics_YYYYMM after the month
+// of clicks they hold. Clicks of other months are held by defaultPartition. Partitions
+// detached by ArchivePartition are moved to archiveSchema.
+const (
+	partitionPrefix  = "analytics_"
+	partitionLayout  = "200601"
+	defaultPartition = "analytics_default"
+	archiveSchema    = "analytics_archive"
+)
+
+// partitionName returns the name of the partition holding clicks of the month.
+func partitionName(month time.Time) string {
+	return partitionPrefix + month.Format(partitionLayout)
+}
+
+// monthStart returns the first moment of the UTC month containing t.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CreatePartition creates the partition holding clicks of the UTC month containing month,
+// unless it already exists. Clicks of the month held by the default partition are moved
+// to the new partition, or to the partition of the month in the archive schema if it was
+// archived, so that an archived month is not recreated.
+func (r *Repository) CreatePartition(ctx context.Context, month time.Time) error {
+	from := monthStart(month)
+	to := from.AddDate(0, 1, 0)
+	name := partitionName(from)
+	archived := archiveSchema + "." + name
+
+	// The bare name is resolved like in CREATE TABLE below, the archived one is qualified.
+	var exists, isArchived bool
+	query := `SELECT to_regclass($1) IS NOT NULL, to_regclass($2) IS NOT NULL;`
+	if err := r.db.Master.QueryRowContext(ctx, query, name, archived).Scan(&exists, &isArchived); err != nil {
+		return fmt.Errorf("check partition %s: %w", name, err)
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if isArchived {
+		if err := moveDefaultRows(ctx, tx, archived, from, to); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit tx: %w", err)
+		}
+
+		return nil
+	}
+
+	// A partition overlapping rows of the default partition cannot be attached, so they are
+	// moved into the partition before attaching it.
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE `+name+` (LIKE analytics INCLUDING DEFAULTS);`); err != nil {
+		return fmt.Errorf("create partition %s: %w", name, err)
+	}
+
+	if err := moveDefaultRows(ctx, tx, name, from, to); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(
+		`ALTER TABLE analytics ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s');`,
+		name, from.Format(time.DateTime), to.Format(time.DateTime),
+	)
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("attach partition %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// moveDefaultRows moves clicks created within [from, to) from the default partition to the table.
+func moveDefaultRows(ctx context.Context, tx *sql.Tx, table string, from, to time.Time) error {
+	query := `
+		WITH moved AS (
+		    DELETE FROM ` + defaultPartition + `
+		    WHERE created_at >= $1 AND created_at < $2
+		    RETURNING *
+		)
+		INSERT INTO ` + table + `
+		SELECT * FROM moved;
+    `
+
+	if _, err := tx.ExecContext(ctx, query, from, to); err != nil {
+		return fmt.Errorf("move clicks to %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// ListDefaultMonths returns UTC months of the clicks held by the default partition in
+// ascending order.
+func (r *Repository) ListDefaultMonths(ctx context.Context) ([]time.Time, error) {
+	query := `
+		SELECT DISTINCT date_trunc('month', created_at) AS month
+		FROM ` + defaultPartition + `
+		ORDER BY month;
+	`
+
+	rows, err := r.db.Master.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query default partition months: %w", err)
+	}
+	defer rows.Close()
+
+	var result []time.Time
+	for rows.Next() {
+		var month time.Time
+
+		if err := rows.Scan(&month); err != nil {
+			return nil, fmt.Errorf("scan default partition months: %w", err)
+		}
+
+		result = append(result, month.UTC())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate default partition months: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListPartitions returns months of the partitions attached to the analytics table in
+// ascending order. Partitions not named after a month are skipped.
+func (r *Repository) ListPartitions(ctx context.Context) ([]time.Time, error) {
+	query := `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'analytics'::regclass
+		ORDER BY c.relname;
+	`
+
+	rows, err := r.db.Master.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []time.Time
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan partitions: %w", err)
+		}
+
+		suffix, ok := strings.CutPrefix(name, partitionPrefix)
+		month, err := time.Parse(partitionLayout, suffix)
+		if !ok || err != nil {
+			continue
+		}
+
+		result = append(result, month)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate partitions: %w", err)
+	}
+
+	return result, nil
+}
+
+// DropPartition deletes the partition holding clicks of the month with all its clicks.
+func (r *Repository) DropPartition(ctx context.Context, month time.Time) error {
+	name := partitionName(monthStart(month))
+
+	if _, err := r.db.ExecContext(ctx, `DROP TABLE IF EXISTS `+name+`;`); err != nil {
+		return fmt.Errorf("drop partition %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// ArchivePartition detaches the partition holding clicks of the month from the analytics
+// table and moves it to the archive schema, so its clicks are kept but no longer queried.
+func (r *Repository) ArchivePartition(ctx context.Context, month time.Time) error {
+	name := partitionName(monthStart(month))
+
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE analytics DETACH PARTITION `+name+`;`); err != nil {
+		return fmt.Errorf("detach partition %s: %w", name, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE `+name+` SET SCHEMA `+archiveSchema+`;`); err != nil {
+		return fmt.Errorf("archive partition %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
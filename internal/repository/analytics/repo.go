@@ -2,14 +2,53 @@ package analytics
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/dbpg"
 
 	"github.com/aliskhannn/url-shortener/internal/model"
 )
 
+var (
+	ErrUnknownDimension = errors.New("unknown dimension")
+)
+
+// dimensionColumns maps dimensions to analytics table columns or expressions over them.
+var dimensionColumns = map[model.Dimension]string{
+	model.DimensionReferrer: "referrer_domain",
+	model.DimensionCampaign: "utm_campaign",
+	model.DimensionLanguage: "language",
+	model.DimensionCountry:  "country",
+	model.DimensionCity:     "CASE WHEN city = '' THEN '' ELSE city || ', ' || country END",
+	model.DimensionRule:     "rule",
+	model.DimensionVariant:  "variant",
+	model.DimensionDevice:   "device_type",
+	model.DimensionOS:       "os_family",
+	model.DimensionBrowser:  "browser",
+	model.DimensionTraffic:  "CASE WHEN is_bot THEN 'bot' ELSE 'human' END",
+}
+
+// rangeCondition restricts analytics to the time range passed as the third and fourth
+// query arguments, see utcOrNil.
+const rangeCondition = `($3::timestamp IS NULL OR created_at >= $3) AND ($4::timestamp IS NULL OR created_at < $4)`
+
+// filterCondition returns the condition selecting analytics of the filter, with its
+// time range passed as the third and fourth query arguments.
+func filterCondition(f model.ClickFilter) string {
+	if f.IncludeBots {
+		return rangeCondition
+	}
+
+	return rangeCondition + ` AND NOT is_bot`
+}
+
 // Repository provides methods to interact with analytics table.
 type Repository struct {
 	db *dbpg.DB
@@ -20,81 +59,251 @@ func NewRepository(db *dbpg.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// SaveAnalytics inserts link analytics into the database and returns its ID.
-func (r *Repository) SaveAnalytics(ctx context.Context, event model.Analytics) (uuid.UUID, error) {
-	query := `
-		INSERT INTO analytics (
-		    alias, user_agent, device_type, os, browser, ip_address
-		) VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id;
-    `
-
-	err := r.db.QueryRowContext(
-		ctx, query, event.Alias, event.UserAgent, event.Device, event.OS, event.Browser, event.IP,
-	).Scan(&event.ID)
+// analyticsColumns lists columns written by SaveAnalyticsBatch, see analyticsArgs.
+var analyticsColumns = []string{
+	"id", "link_id", "workspace_id", "alias", "user_agent", "device_type", "os", "os_family", "browser", "ip_address",
+	"ip_hash", "visitor_id", "is_bot", "bot_reason", "source",
+	"referrer", "referrer_domain", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "language",
+	"country", "region", "city", "asn", "as_org", "rule", "variant", "created_at",
+}
+
+// maxBatchRows bounds rows per INSERT statement to keep within the limit of query arguments.
+const maxBatchRows = 1000
+
+// SaveAnalyticsBatch inserts link analytics into the database with multi-row INSERT statements
+// in a single transaction, so either all events of the batch are saved or none.
+// Events already saved under the same ID are skipped, so redelivered events are saved once.
+// Events without an ID get a new one, events without a time are saved at the current time.
+// It returns the saved events, without the skipped ones.
+func (r *Repository) SaveAnalyticsBatch(ctx context.Context, events []model.Analytics) ([]model.Analytics, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("insert analytics: %w", err)
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	saved := make([]model.Analytics, 0, len(events))
+	for len(events) > 0 {
+		n := min(len(events), maxBatchRows)
+		inserted, err := insertAnalytics(ctx, tx, events[:n])
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, inserted...)
+		events = events[n:]
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
 	}
 
-	return event.ID, nil
+	return saved, nil
 }
 
-// CountClicks returns the total number of clicks for a short link alias.
-func (r *Repository) CountClicks(ctx context.Context, alias string) (int, error) {
-	var count int
+// insertAnalytics inserts link analytics and adds them to the rollups with a single statement.
+// It returns the inserted events.
+func insertAnalytics(ctx context.Context, tx *sql.Tx, events []model.Analytics) ([]model.Analytics, error) {
+	var b strings.Builder
+	b.WriteString("INSERT INTO analytics (" + strings.Join(analyticsColumns, ", ") + ") VALUES ")
+
+	pending := make(map[uuid.UUID]model.Analytics, len(events))
+	args := make([]interface{}, 0, len(events)*len(analyticsColumns))
+	for i, event := range events {
+		if event.ID == uuid.Nil {
+			event.ID = uuid.New()
+		}
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+		pending[event.ID] = event
+
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString("(")
+		for j := range analyticsColumns {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("$" + strconv.Itoa(len(args)+j+1))
+		}
+		b.WriteString(")")
+
+		args = append(args, analyticsArgs(event)...)
+	}
 
-	query := `SELECT COUNT(*) FROM analytics WHERE alias = $1;`
+	b.WriteString(" ON CONFLICT DO NOTHING")
 
-	err := r.db.QueryRowContext(ctx, query, alias).Scan(&count)
+	rows, err := tx.QueryContext(ctx, rollupStatement(b.String()), args...)
+	if err != nil {
+		return nil, fmt.Errorf("insert analytics: %w", err)
+	}
+	defer rows.Close()
+
+	inserted := make([]model.Analytics, 0, len(events))
+	for rows.Next() {
+		var id uuid.UUID
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan inserted analytics: %w", err)
+		}
+
+		inserted = append(inserted, pending[id])
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate inserted analytics: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// analyticsArgs returns values of analyticsColumns of the event.
+func analyticsArgs(event model.Analytics) []interface{} {
+	return []interface{}{
+		event.ID, event.LinkID, event.WorkspaceID, event.Alias, event.UserAgent, event.Device, event.OS, event.OSFamily,
+		event.Browser, nullIfEmpty(event.IP), event.IPHash, event.VisitorID, event.IsBot, event.BotReason, event.Source,
+		event.Referrer, event.ReferrerDomain, event.UTMSource, event.UTMMedium, event.UTMCampaign,
+		event.UTMTerm, event.UTMContent, event.Language,
+		event.Country, event.Region, event.City, event.ASN, event.ASOrg, event.Rule, event.Variant,
+		event.CreatedAt.UTC(),
+	}
+}
+
+// CountClicks returns the total number of clicks for a link within the workspace and time range.
+func (r *Repository) CountClicks(
+	ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter,
+) (int, error) {
+	counts, err := r.countRollup(ctx, workspaceID, linkID, f, "''", rollupTotal, "value", true, 0)
 	if err != nil {
 		return 0, fmt.Errorf("count clicks: %w", err)
 	}
 
+	var count int
+	for _, c := range counts {
+		count += c.Clicks
+	}
+
 	return count, nil
 }
 
-// GetClicksByDay returns number of clicks grouped by day.
-func (r *Repository) GetClicksByDay(ctx context.Context, alias string) (map[string]int, error) {
+// CountVisitors returns the number of unique visitors of a link within the workspace and time range.
+func (r *Repository) CountVisitors(
+	ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter,
+) (int, error) {
+	var count int
+
 	query := `
-		SELECT TO_CHAR(created_at, 'YYYY-MM-DD') AS day, COUNT(*)
+		SELECT COUNT(DISTINCT visitor_id)
 		FROM analytics
-		WHERE alias = $1
-		GROUP BY day
-		ORDER BY day DESC;
-    `
+		WHERE workspace_id = $1 AND link_id = $2 AND ` + filterCondition(f) + ` AND visitor_id <> '';
+	`
 
-	rows, err := r.db.QueryContext(ctx, query, alias)
+	err := r.db.QueryRowContext(ctx, query, workspaceID, linkID, utcOrNil(f.From), utcOrNil(f.To)).Scan(&count)
 	if err != nil {
-		return nil, fmt.Errorf("query clicks by day: %w", err)
+		return 0, fmt.Errorf("count visitors: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetVisitorIDs returns at most limit distinct visitor IDs of human clicks on a link within
+// the workspace greater than after, in ascending order.
+func (r *Repository) GetVisitorIDs(
+	ctx context.Context, workspaceID, linkID uuid.UUID, after string, limit int,
+) ([]string, error) {
+	query := `
+		SELECT DISTINCT visitor_id
+		FROM analytics
+		WHERE workspace_id = $1 AND link_id = $2 AND visitor_id > $3 AND NOT is_bot
+		ORDER BY visitor_id
+		LIMIT $4;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID, linkID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query visitor ids: %w", err)
 	}
 	defer rows.Close()
 
-	result := make(map[string]int)
+	result := make([]string, 0, limit)
 	for rows.Next() {
-		var day string
-		var count int
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan visitor ids: %w", err)
+		}
+
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate visitor ids: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetClickSeries returns number of clicks for a link within the workspace and time range
+// grouped by buckets of the granularity on the wall clock of the time zone, ordered by time.
+// Bucket times are wall clock times of the zone returned in UTC, empty buckets are omitted.
+// Whole rollup buckets are read from the rollups where the zone allows, see seriesSegments.
+func (r *Repository) GetClickSeries(
+	ctx context.Context,
+	workspaceID, linkID uuid.UUID,
+	f model.ClickFilter,
+	granularity model.Granularity,
+	timezone string,
+) ([]model.TimeBucket, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = nil // zones unknown to Go may still be known to Postgres
+	}
+
+	var q rollupQuery
+	query := q.series(workspaceID, linkID, f, granularity, timezone, loc)
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query click series: %w", err)
+	}
+	defer rows.Close()
 
-		if err := rows.Scan(&day, &count); err != nil {
-			return nil, fmt.Errorf("scan clicks by day: %w", err)
+	var result []model.TimeBucket
+	for rows.Next() {
+		var b model.TimeBucket
+
+		if err := rows.Scan(&b.Time, &b.Clicks, &b.UniqueVisitors); err != nil {
+			return nil, fmt.Errorf("scan click series: %w", err)
 		}
 
-		result[day] = count
+		b.Time = b.Time.UTC()
+		result = append(result, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate click series: %w", err)
 	}
 
 	return result, nil
 }
 
-// GetClicksByUserAgent returns number of clicks grouped by User-Agent.
-func (r *Repository) GetClicksByUserAgent(ctx context.Context, alias string) (map[string]int, error) {
+// GetClicksByUserAgent returns number of clicks for a link within the workspace and time range grouped by User-Agent.
+func (r *Repository) GetClicksByUserAgent(
+	ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter,
+) (map[string]int, error) {
 	query := `
 		SELECT user_agent, COUNT(*) 
 		FROM analytics
-		WHERE alias = $1
+		WHERE workspace_id = $1 AND link_id = $2 AND ` + filterCondition(f) + `
 		GROUP BY user_agent
 		ORDER BY COUNT(*) DESC;
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, alias)
+	rows, err := r.db.QueryContext(ctx, query, workspaceID, linkID, utcOrNil(f.From), utcOrNil(f.To))
 	if err != nil {
 		return nil, fmt.Errorf("query clicks by user-agent: %w", err)
 	}
@@ -114,3 +323,135 @@ func (r *Repository) GetClicksByUserAgent(ctx context.Context, alias string) (ma
 
 	return result, nil
 }
+
+// GetClicksBySource returns number of clicks for a link within the workspace and time range grouped by
+// traffic source, with visits without a source marker reported as "direct".
+func (r *Repository) GetClicksBySource(
+	ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter,
+) (map[string]int, error) {
+	counts, err := r.countRollup(
+		ctx, workspaceID, linkID, f,
+		"COALESCE(NULLIF(source, ''), 'direct')", rollupSource, "COALESCE(NULLIF(value, ''), 'direct')", true, 0,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int, len(counts))
+	for _, c := range counts {
+		result[c.Value] = c.Clicks
+	}
+
+	return result, nil
+}
+
+// GetTopValues returns at most limit most clicked non-empty values of the dimension
+// for a link within the workspace and time range, ordered by clicks.
+func (r *Repository) GetTopValues(
+	ctx context.Context,
+	workspaceID, linkID uuid.UUID,
+	f model.ClickFilter,
+	dimension model.Dimension,
+	limit int,
+) ([]model.DimensionCount, error) {
+	column, ok := dimensionColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDimension, dimension)
+	}
+
+	rollup, rolled := string(dimension), "value"
+	if dimension == model.DimensionTraffic {
+		rollup, rolled = rollupTotal, column
+	}
+
+	result, err := r.countRollup(ctx, workspaceID, linkID, f, column, rollup, rolled, false, limit)
+	if err != nil {
+		return nil, fmt.Errorf("top values: %w", err)
+	}
+
+	if result == nil {
+		result = []model.DimensionCount{}
+	}
+
+	return result, nil
+}
+
+// DeleteAnalyticsByIP deletes clicks from the client IP, stored as is or as one of the
+// hashes, including clicks of archived partitions, subtracts them from the rollups and
+// returns the number of deleted clicks.
+func (r *Repository) DeleteAnalyticsByIP(ctx context.Context, ip string, hashes []string) (int64, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	archived, err := archivedTables(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, table := range append([]string{"analytics"}, archived...) {
+		query := unrollStatement(`DELETE FROM ` + table + ` WHERE ip_address = $1 OR ip_hash = ANY($2)`)
+
+		var n int64
+		if err := tx.QueryRowContext(ctx, query, ip, pq.StringArray(hashes)).Scan(&n); err != nil {
+			return 0, fmt.Errorf("delete analytics by ip from %s: %w", table, err)
+		}
+
+		total += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return total, nil
+}
+
+// archivedTables returns qualified names of the partitions moved to the archive schema.
+func archivedTables(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT tablename FROM pg_tables WHERE schemaname = $1;`, archiveSchema)
+	if err != nil {
+		return nil, fmt.Errorf("query archived partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan archived partitions: %w", err)
+		}
+
+		result = append(result, pq.QuoteIdentifier(archiveSchema)+"."+pq.QuoteIdentifier(name))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate archived partitions: %w", err)
+	}
+
+	return result, nil
+}
+
+// nullIfEmpty returns s, or nil if it is empty.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// utcOrNil returns t in UTC, or nil if it is zero.
+func utcOrNil(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC()
+}
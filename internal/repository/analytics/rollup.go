@@ -0,0 +1,451 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// Rollup tables hold numbers of clicks per link, UTC bucket, dimension value and bot flag.
+// They are maintained by SaveAnalyticsBatch in the statement inserting the clicks.
+const (
+	rawTable    = "analytics"
+	hourlyTable = "analytics_hourly"
+	dailyTable  = "analytics_daily"
+)
+
+// Rollup dimensions besides dimensionColumns: every click is counted under rollupTotal
+// with an empty value and under rollupSource with its source marker. Visitor IDs are not
+// rolled up, distinct visitors are counted from raw rows.
+const (
+	rollupTotal  = "total"
+	rollupSource = "source"
+)
+
+// rollupColumns maps rollup dimensions to analytics columns or expressions over them.
+// Clicks with an empty value are counted only under rollupTotal and rollupSource.
+var rollupColumns = func() [][2]string {
+	columns := [][2]string{{rollupTotal, "''"}, {rollupSource, "source"}}
+
+	dimensions := make([]string, 0, len(dimensionColumns))
+	for dimension := range dimensionColumns {
+		// Traffic is read from rollupTotal grouped by the bot flag.
+		if dimension != model.DimensionTraffic {
+			dimensions = append(dimensions, string(dimension))
+		}
+	}
+	sort.Strings(dimensions)
+
+	for _, dimension := range dimensions {
+		columns = append(columns, [2]string{dimension, dimensionColumns[model.Dimension(dimension)]})
+	}
+
+	return columns
+}()
+
+// rollupStatement wraps the INSERT of analytics rows into a statement adding the inserted
+// rows to the hourly and daily rollups and returning their IDs. Rows skipped as duplicates
+// are neither counted nor returned.
+func rollupStatement(insert string) string {
+	upsert := func(table, unit string) string {
+		return `
+		INSERT INTO ` + table + ` (link_id, workspace_id, bucket, dimension, value, is_bot, clicks)
+		SELECT link_id, workspace_id, date_trunc('` + unit + `', created_at), dimension, value, is_bot, COUNT(*)
+		FROM dimensions
+		GROUP BY 1, 2, 3, 4, 5, 6
+		ORDER BY 1, 2, 3, 4, 5, 6
+		ON CONFLICT (link_id, bucket, dimension, value, is_bot)
+		    DO UPDATE SET clicks = ` + table + `.clicks + EXCLUDED.clicks`
+	}
+
+	return `
+		WITH inserted AS (` + insert + ` RETURNING *),
+		dimensions AS (` + rollupDimensions("inserted") + `),
+		hourly AS (` + upsert(hourlyTable, "hour") + `),
+		daily AS (` + upsert(dailyTable, "day") + `)
+		SELECT id FROM inserted;`
+}
+
+// unrollStatement wraps the DELETE of analytics rows into a statement subtracting the
+// deleted rows from the hourly and daily rollups and returning their number. Rollup rows
+// left without clicks are deleted.
+func unrollStatement(del string) string {
+	counts := func(unit string) string {
+		return `
+		    SELECT link_id, date_trunc('` + unit + `', created_at) AS bucket, dimension, value, is_bot, COUNT(*) AS clicks
+		    FROM dimensions
+		    GROUP BY 1, 2, 3, 4, 5`
+	}
+
+	// Rows with more clicks than deleted are updated, the others deleted, so that no rollup
+	// row is modified twice by the statement.
+	subtract := func(table, counts string) string {
+		match := `r.link_id = c.link_id AND r.bucket = c.bucket AND r.dimension = c.dimension
+		      AND r.value = c.value AND r.is_bot = c.is_bot`
+
+		return table + `_updated AS (
+		    UPDATE ` + table + ` r SET clicks = r.clicks - c.clicks
+		    FROM ` + counts + ` c
+		    WHERE ` + match + ` AND r.clicks > c.clicks
+		),
+		` + table + `_deleted AS (
+		    DELETE FROM ` + table + ` r
+		    USING ` + counts + ` c
+		    WHERE ` + match + ` AND r.clicks <= c.clicks
+		)`
+	}
+
+	return `
+		WITH deleted AS (` + del + ` RETURNING *),
+		dimensions AS (` + rollupDimensions("deleted") + `),
+		hourly_counts AS (` + counts("hour") + `),
+		daily_counts AS (` + counts("day") + `),
+		` + subtract(hourlyTable, "hourly_counts") + `,
+		` + subtract(dailyTable, "daily_counts") + `
+		SELECT COUNT(*) FROM deleted;`
+}
+
+// rollupDimensions returns a query selecting link, creation time, bot flag, rollup
+// dimension and value of the rows, one row per rollup dimension they are counted under.
+func rollupDimensions(rows string) string {
+	var values strings.Builder
+	for i, c := range rollupColumns {
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		values.WriteString("('" + c[0] + "', " + c[1] + ")")
+	}
+
+	return `
+		    SELECT i.link_id, i.workspace_id, i.created_at, i.is_bot, d.dimension, d.value
+		    FROM ` + rows + ` i
+		    CROSS JOIN LATERAL (VALUES ` + values.String() + `) AS d (dimension, value)
+		    WHERE d.value <> '' OR d.dimension IN ('` + rollupTotal + `', '` + rollupSource + `')
+		`
+}
+
+// segment is a part of a time range whose clicks are read from a single table.
+// Zero bounds leave that side open.
+type segment struct {
+	table    string
+	from, to time.Time
+}
+
+// planSegments splits the time range into whole UTC days read from the daily rollup,
+// whole UTC hours read from the hourly rollup and the remaining partial hours read from
+// raw analytics rows. Zero bounds leave that side open.
+func planSegments(from, to time.Time) []segment {
+	from, to = from.UTC(), to.UTC()
+
+	dayFrom, dayTo := ceil(from, 24*time.Hour), floor(to, 24*time.Hour)
+	if !earlier(dayFrom, dayTo) {
+		return hourSegments(from, to)
+	}
+
+	segments := []segment{{table: dailyTable, from: dayFrom, to: dayTo}}
+	if !from.IsZero() {
+		segments = append(segments, hourSegments(from, dayFrom)...)
+	}
+	if !to.IsZero() {
+		segments = append(segments, hourSegments(dayTo, to)...)
+	}
+
+	return segments
+}
+
+// hourSegments splits the time range into whole UTC hours read from the hourly rollup and
+// the remaining partial hours read from raw analytics rows. Zero bounds leave that side open.
+func hourSegments(from, to time.Time) []segment {
+	from, to = from.UTC(), to.UTC()
+
+	hourFrom, hourTo := ceil(from, time.Hour), floor(to, time.Hour)
+	if !earlier(hourFrom, hourTo) {
+		if from.Before(to) {
+			return []segment{{table: rawTable, from: from, to: to}}
+		}
+
+		return nil
+	}
+
+	segments := []segment{{table: hourlyTable, from: hourFrom, to: hourTo}}
+	if from.Before(hourFrom) {
+		segments = append(segments, segment{table: rawTable, from: from, to: hourFrom})
+	}
+	if hourTo.Before(to) {
+		segments = append(segments, segment{table: rawTable, from: hourTo, to: to})
+	}
+
+	return segments
+}
+
+// seriesSegments splits the time range of a time series with buckets of the granularity in
+// the time zone into segments, see planSegments. Rollup buckets are read only where each of
+// them lies within a single series bucket: daily ones for buckets of days or longer in zones
+// at UTC, hourly ones for buckets of hours or longer in zones offset by whole hours.
+// Only raw rows are read for a nil zone.
+func seriesSegments(from, to time.Time, g model.Granularity, loc *time.Location) []segment {
+	var whole, zero bool
+	if loc != nil {
+		whole, zero = zoneOffsets(loc, from, to)
+	}
+
+	switch {
+	case g == model.GranularityMinute || !whole:
+		if earlier(from, to) {
+			return []segment{{table: rawTable, from: from.UTC(), to: to.UTC()}}
+		}
+		return nil
+	case zero && g != model.GranularityHour:
+		return planSegments(from, to)
+	default:
+		return hourSegments(from, to)
+	}
+}
+
+// zoneOffsets reports whether all UTC offsets of the time zone within the time range are
+// whole hours and whether all of them are zero. Open bounds stand for 1970 and a year from now.
+func zoneOffsets(loc *time.Location, from, to time.Time) (whole, zero bool) {
+	if from.IsZero() {
+		from = time.Unix(0, 0)
+	}
+	if to.IsZero() {
+		to = time.Now().AddDate(1, 0, 0)
+	}
+
+	whole, zero = true, true
+	for t := from.In(loc); t.Before(to); {
+		_, offset := t.Zone()
+		whole = whole && offset%3600 == 0
+		zero = zero && offset == 0
+
+		_, end := t.ZoneBounds()
+		if end.IsZero() {
+			break // the zone lasts forever
+		}
+		t = end
+	}
+
+	return whole, zero
+}
+
+// earlier reports whether the lower bound from is before the upper bound to,
+// treating zero bounds as open.
+func earlier(from, to time.Time) bool {
+	return from.IsZero() || to.IsZero() || from.Before(to)
+}
+
+// floor truncates t to a multiple of d, keeping zero times zero.
+func floor(t time.Time, d time.Duration) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	return t.Truncate(d)
+}
+
+// ceil rounds t up to a multiple of d, keeping zero times zero.
+func ceil(t time.Time, d time.Duration) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	if f := t.Truncate(d); f.Before(t) {
+		return f.Add(d)
+	}
+
+	return t
+}
+
+// rollupQuery builds queries summing clicks of a dimension over the segments of a time range.
+type rollupQuery struct {
+	args []interface{}
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *rollupQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// build returns a query selecting value and clicks of the dimension, one row per value,
+// ordered by clicks. Values are read from raw rows with the raw expression and from the
+// rollup dimension with the rolled expression over its value and is_bot columns.
+// Empty values are skipped unless keepEmpty is set, limit of zero returns all values.
+func (q *rollupQuery) build(
+	workspaceID, linkID uuid.UUID,
+	f model.ClickFilter,
+	raw, dimension, rolled string,
+	keepEmpty bool,
+	limit int,
+) string {
+	workspace, link := q.arg(workspaceID), q.arg(linkID)
+
+	// Unused arguments fail the query, so the dimension is added only when a rollup is read.
+	var rollup string
+
+	segments := planSegments(f.From, f.To)
+	parts := make([]string, 0, len(segments))
+	for _, s := range segments {
+		column, value, clicks := "bucket", rolled, "clicks"
+		if s.table == rawTable {
+			column, value, clicks = "created_at", raw, "1"
+		}
+
+		part := `
+		    SELECT ` + value + ` AS value, ` + clicks + ` AS clicks
+		    FROM ` + s.table + `
+		    WHERE workspace_id = ` + workspace + ` AND link_id = ` + link
+		if s.table != rawTable {
+			if rollup == "" {
+				rollup = q.arg(dimension)
+			}
+			part += ` AND dimension = ` + rollup
+		}
+
+		parts = append(parts, part+q.conditions(s, column, f))
+	}
+
+	if len(parts) == 0 {
+		// Empty time range.
+		parts = append(parts, `SELECT ''::text AS value, 0::bigint AS clicks WHERE FALSE`)
+	}
+
+	query := `
+		SELECT value, SUM(clicks) AS clicks
+		FROM (` + strings.Join(parts, "\n\t\t    UNION ALL") + `
+		) c`
+	if !keepEmpty {
+		query += `
+		WHERE value <> ''`
+	}
+	query += `
+		GROUP BY value
+		ORDER BY clicks DESC, value`
+	if limit > 0 {
+		query += `
+		LIMIT ` + q.arg(limit)
+	}
+
+	return query + ";"
+}
+
+// series returns a query selecting the start of each bucket of the granularity in the time
+// zone with clicks, its number of clicks and of distinct visitors, ordered by bucket.
+// Clicks are read from the segments of the time range, visitors from raw rows, so buckets
+// whose raw rows expired count no visitors.
+func (q *rollupQuery) series(
+	workspaceID, linkID uuid.UUID,
+	f model.ClickFilter,
+	granularity model.Granularity,
+	timezone string,
+	loc *time.Location,
+) string {
+	workspace, link := q.arg(workspaceID), q.arg(linkID)
+
+	segments := seriesSegments(f.From, f.To, granularity, loc)
+	parts := make([]string, 0, len(segments))
+	for _, s := range segments {
+		if s.table == rawTable {
+			parts = append(parts, `
+		    SELECT created_at AS at, 1 AS clicks
+		    FROM `+s.table+`
+		    WHERE workspace_id = `+workspace+` AND link_id = `+link+q.conditions(s, "created_at", f))
+			continue
+		}
+
+		parts = append(parts, `
+		    SELECT bucket AS at, clicks
+		    FROM `+s.table+`
+		    WHERE workspace_id = `+workspace+` AND link_id = `+link+`
+		      AND dimension = '`+rollupTotal+`'`+q.conditions(s, "bucket", f))
+	}
+
+	if len(parts) == 0 {
+		// Empty time range.
+		parts = append(parts, `SELECT NOW()::timestamp AS at, 0::bigint AS clicks WHERE FALSE`)
+	}
+
+	visitors := q.conditions(segment{table: rawTable, from: f.From, to: f.To}, "created_at", f)
+	granularityArg, timezoneArg := q.arg(string(granularity)), q.arg(timezone)
+	bucket := func(column string) string {
+		return `date_trunc(` + granularityArg + `, (` + column + ` AT TIME ZONE 'UTC') AT TIME ZONE ` + timezoneArg + `)`
+	}
+
+	return `
+		WITH clicks AS (
+		    SELECT ` + bucket("at") + ` AS bucket, SUM(clicks) AS clicks
+		    FROM (` + strings.Join(parts, "\n\t\t    UNION ALL") + `
+		    ) c
+		    GROUP BY 1
+		),
+		visitors AS (
+		    SELECT ` + bucket("created_at") + ` AS bucket, COUNT(DISTINCT visitor_id) AS visitors
+		    FROM ` + rawTable + `
+		    WHERE workspace_id = ` + workspace + ` AND link_id = ` + link + ` AND visitor_id <> ''` + visitors + `
+		    GROUP BY 1
+		)
+		SELECT c.bucket, c.clicks, COALESCE(v.visitors, 0)
+		FROM clicks c
+		LEFT JOIN visitors v ON v.bucket = c.bucket
+		ORDER BY c.bucket;`
+}
+
+// conditions returns conditions restricting rows of the segment to its bounds on the column
+// and to the bot flag of the filter.
+func (q *rollupQuery) conditions(s segment, column string, f model.ClickFilter) string {
+	var conds string
+	if !s.from.IsZero() {
+		conds += ` AND ` + column + ` >= ` + q.arg(s.from)
+	}
+	if !s.to.IsZero() {
+		conds += ` AND ` + column + ` < ` + q.arg(s.to)
+	}
+	if !f.IncludeBots {
+		conds += ` AND NOT is_bot`
+	}
+
+	return conds
+}
+
+// countRollup returns numbers of clicks per value of the dimension, see rollupQuery.build.
+func (r *Repository) countRollup(
+	ctx context.Context,
+	workspaceID, linkID uuid.UUID,
+	f model.ClickFilter,
+	raw, dimension, rolled string,
+	keepEmpty bool,
+	limit int,
+) ([]model.DimensionCount, error) {
+	var q rollupQuery
+	query := q.build(workspaceID, linkID, f, raw, dimension, rolled, keepEmpty, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query clicks by %s: %w", dimension, err)
+	}
+	defer rows.Close()
+
+	var result []model.DimensionCount
+	for rows.Next() {
+		var dc model.DimensionCount
+
+		if err := rows.Scan(&dc.Value, &dc.Clicks); err != nil {
+			return nil, fmt.Errorf("scan clicks by %s: %w", dimension, err)
+		}
+
+		result = append(result, dc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate clicks by %s: %w", dimension, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// at returns the UTC time of 2025-01-day at hh:mm.
+func at(day, hh, mm int) time.Time {
+	return time.Date(2025, time.January, day, hh, mm, 0, 0, time.UTC)
+}
+
+func TestPlanSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []segment
+	}{
+		{
+			name: "open range",
+			want: []segment{{table: dailyTable}},
+		},
+		{
+			name: "open end",
+			from: at(1, 10, 30),
+			want: []segment{
+				{table: dailyTable, from: at(2, 0, 0)},
+				{table: hourlyTable, from: at(1, 11, 0), to: at(2, 0, 0)},
+				{table: rawTable, from: at(1, 10, 30), to: at(1, 11, 0)},
+			},
+		},
+		{
+			name: "open start",
+			to:   at(3, 5, 15),
+			want: []segment{
+				{table: dailyTable, to: at(3, 0, 0)},
+				{table: hourlyTable, from: at(3, 0, 0), to: at(3, 5, 0)},
+				{table: rawTable, from: at(3, 5, 0), to: at(3, 5, 15)},
+			},
+		},
+		{
+			name: "days with partial edges",
+			from: at(1, 10, 30),
+			to:   at(5, 3, 15),
+			want: []segment{
+				{table: dailyTable, from: at(2, 0, 0), to: at(5, 0, 0)},
+				{table: hourlyTable, from: at(1, 11, 0), to: at(2, 0, 0)},
+				{table: rawTable, from: at(1, 10, 30), to: at(1, 11, 0)},
+				{table: hourlyTable, from: at(5, 0, 0), to: at(5, 3, 0)},
+				{table: rawTable, from: at(5, 3, 0), to: at(5, 3, 15)},
+			},
+		},
+		{
+			name: "whole days",
+			from: at(1, 0, 0),
+			to:   at(3, 0, 0),
+			want: []segment{{table: dailyTable, from: at(1, 0, 0), to: at(3, 0, 0)}},
+		},
+		{
+			name: "hours within a day",
+			from: at(1, 10, 30),
+			to:   at(1, 13, 0),
+			want: []segment{
+				{table: hourlyTable, from: at(1, 11, 0), to: at(1, 13, 0)},
+				{table: rawTable, from: at(1, 10, 30), to: at(1, 11, 0)},
+			},
+		},
+		{
+			name: "within an hour",
+			from: at(1, 10, 10),
+			to:   at(1, 10, 50),
+			want: []segment{{table: rawTable, from: at(1, 10, 10), to: at(1, 10, 50)}},
+		},
+		{
+			name: "empty range",
+			from: at(1, 10, 50),
+			to:   at(1, 10, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planSegments(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planSegments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesSegments(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	from, to := at(1, 10, 30), at(3, 5, 15)
+	raw := []segment{{table: rawTable, from: from, to: to}}
+	hourly := []segment{
+		{table: hourlyTable, from: at(1, 11, 0), to: at(3, 5, 0)},
+		{table: rawTable, from: from, to: at(1, 11, 0)},
+		{table: rawTable, from: at(3, 5, 0), to: to},
+	}
+
+	tests := []struct {
+		name        string
+		granularity model.Granularity
+		loc         *time.Location
+		want        []segment
+	}{
+		{"days in UTC", model.GranularityDay, time.UTC, planSegments(from, to)},
+		{"months in UTC", model.GranularityMonth, time.UTC, planSegments(from, to)},
+		{"hours in UTC", model.GranularityHour, time.UTC, hourly},
+		{"minutes in UTC", model.GranularityMinute, time.UTC, raw},
+		{"days in whole hour zone", model.GranularityDay, newYork, hourly},
+		{"weeks in whole hour zone", model.GranularityWeek, newYork, hourly},
+		{"days in half hour zone", model.GranularityDay, kolkata, raw},
+		{"unknown zone", model.GranularityDay, nil, raw},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seriesSegments(from, to, tt.granularity, tt.loc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("seriesSegments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZoneOffsets(t *testing.T) {
+	tests := []struct {
+		name      string
+		loc       *time.Location
+		wantWhole bool
+		wantZero  bool
+	}{
+		{"utc", time.UTC, true, true},
+		{"whole hours", time.FixedZone("UTC+3", 3*60*60), true, false},
+		{"half hours", time.FixedZone("UTC+5:30", 5*60*60+30*60), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whole, zero := zoneOffsets(tt.loc, at(1, 0, 0), at(2, 0, 0))
+			if whole != tt.wantWhole || zero != tt.wantZero {
+				t.Errorf("zoneOffsets() = %t, %t, want %t, %t", whole, zero, tt.wantWhole, tt.wantZero)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+var (
+	ErrKeyNotFound = errors.New("api key not found")
+)
+
+// Repository provides methods to interact with api_keys table.
+type Repository struct {
+	db *dbpg.DB
+}
+
+// NewRepository creates a new API key repository.
+func NewRepository(db *dbpg.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateAPIKey inserts a new API key into the database and returns it.
+func (r *Repository) CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error) {
+	query := `
+		INSERT INTO api_keys (owner_id, name, prefix, key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;
+    `
+
+	err := r.db.Master.QueryRowContext(
+		ctx, query, key.OwnerID, key.Name, key.Prefix, key.Hash,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return model.APIKey{}, fmt.Errorf("insert api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetActiveAPIKeyByHash retrieves a non-revoked API key by the hash of its value.
+func (r *Repository) GetActiveAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	query := `
+		SELECT id, owner_id, name, prefix, key_hash, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL;
+    `
+
+	var key model.APIKey
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(
+		&key.ID, &key.OwnerID, &key.Name, &key.Prefix, &key.Hash,
+		&key.CreatedAt, &key.LastUsedAt, &key.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.APIKey{}, ErrKeyNotFound
+		}
+
+		return model.APIKey{}, fmt.Errorf("get api key by hash: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns all API keys of the owner, newest first.
+func (r *Repository) ListAPIKeys(ctx context.Context, ownerID uuid.UUID) ([]model.APIKey, error) {
+	query := `
+		SELECT id, owner_id, name, prefix, key_hash, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE owner_id = $1
+		ORDER BY created_at DESC;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]model.APIKey, 0)
+	for rows.Next() {
+		var key model.APIKey
+
+		err := rows.Scan(
+			&key.ID, &key.OwnerID, &key.Name, &key.Prefix, &key.Hash,
+			&key.CreatedAt, &key.LastUsedAt, &key.RevokedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks the owner's API key as revoked.
+func (r *Repository) RevokeAPIKey(ctx context.Context, ownerID, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL;
+    `
+
+	res, err := r.db.ExecContext(ctx, query, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get revoked api keys count: %w", err)
+	}
+
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey updates the last usage timestamp of the API key.
+func (r *Repository) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1;`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+var (
+	ErrDomainNotFound = errors.New("domain not found")
+	ErrDomainExists   = errors.New("domain already registered")
+	ErrDomainTaken    = errors.New("domain verified by another workspace")
+)
+
+// uniqueViolation is the PostgreSQL error code of unique constraint violations.
+const uniqueViolation = "23505"
+
+// domainColumns lists domains table columns in the order expected by scanDomain.
+const domainColumns = `id, workspace_id, hostname, verification_token, verified_at, created_at`
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Repository provides methods to interact with domains table.
+type Repository struct {
+	db *dbpg.DB
+}
+
+// NewRepository creates a new domain repository.
+func NewRepository(db *dbpg.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateDomain inserts a new domain into the database and returns it.
+func (r *Repository) CreateDomain(ctx context.Context, d model.Domain) (model.Domain, error) {
+	query := `
+		INSERT INTO domains (workspace_id, hostname, verification_token)
+		VALUES ($1, $2, $3)
+		RETURNING ` + domainColumns + `;
+    `
+
+	res, err := scanDomain(r.db.Master.QueryRowContext(ctx, query, d.WorkspaceID, d.Hostname, d.VerificationToken))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return model.Domain{}, ErrDomainExists
+		}
+
+		return model.Domain{}, fmt.Errorf("insert domain: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetDomain retrieves the domain of the workspace by its hostname.
+func (r *Repository) GetDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error) {
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE workspace_id = $1 AND hostname = $2;
+    `
+
+	d, err := scanDomain(r.db.QueryRowContext(ctx, query, workspaceID, hostname))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Domain{}, ErrDomainNotFound
+		}
+
+		return model.Domain{}, fmt.Errorf("get domain: %w", err)
+	}
+
+	return d, nil
+}
+
+// GetVerifiedDomain retrieves the verified domain by its hostname regardless of workspace.
+func (r *Repository) GetVerifiedDomain(ctx context.Context, hostname string) (model.Domain, error) {
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE hostname = $1 AND verified_at IS NOT NULL;
+    `
+
+	d, err := scanDomain(r.db.QueryRowContext(ctx, query, hostname))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Domain{}, ErrDomainNotFound
+		}
+
+		return model.Domain{}, fmt.Errorf("get verified domain: %w", err)
+	}
+
+	return d, nil
+}
+
+// ListDomains returns all domains of the workspace.
+func (r *Repository) ListDomains(ctx context.Context, workspaceID uuid.UUID) ([]model.Domain, error) {
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE workspace_id = $1
+		ORDER BY hostname;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := make([]model.Domain, 0)
+	for rows.Next() {
+		d, err := scanDomain(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan domain: %w", err)
+		}
+
+		domains = append(domains, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// MarkVerified marks the domain as verified and returns it.
+func (r *Repository) MarkVerified(ctx context.Context, id uuid.UUID) (model.Domain, error) {
+	query := `
+		UPDATE domains
+		SET verified_at = NOW()
+		WHERE id = $1
+		RETURNING ` + domainColumns + `;
+    `
+
+	d, err := scanDomain(r.db.Master.QueryRowContext(ctx, query, id))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return model.Domain{}, ErrDomainTaken
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Domain{}, ErrDomainNotFound
+		}
+
+		return model.Domain{}, fmt.Errorf("mark domain verified: %w", err)
+	}
+
+	return d, nil
+}
+
+// DeleteDomain deletes the domain of the workspace.
+func (r *Repository) DeleteDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) error {
+	query := `DELETE FROM domains WHERE workspace_id = $1 AND hostname = $2;`
+
+	res, err := r.db.ExecContext(ctx, query, workspaceID, hostname)
+	if err != nil {
+		return fmt.Errorf("delete domain: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get deleted domains count: %w", err)
+	}
+
+	if n == 0 {
+		return ErrDomainNotFound
+	}
+
+	return nil
+}
+
+// scanDomain scans a row selected with domainColumns into a Domain.
+func scanDomain(row scanner) (model.Domain, error) {
+	var d model.Domain
+	err := row.Scan(&d.ID, &d.WorkspaceID, &d.Hostname, &d.VerificationToken, &d.VerifiedAt, &d.CreatedAt)
+	return d, err
+}
@@ -3,9 +3,15 @@ package link
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/dbpg"
 
 	"github.com/aliskhannn/url-shortener/internal/model"
@@ -15,6 +21,14 @@ var (
 	ErrAliasNotFound = errors.New("link not found")
 )
 
+// linkColumns lists links table columns in the order expected by scanLink.
+const linkColumns = `id, workspace_id, domain, url, alias, owner_id, enabled, redirect_code, redirect_mode, tracking_pixels, query_passthrough, path_passthrough, rules, variants, privacy_mode, expires_at, archived_at, created_at, updated_at`
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
 // Repository provides methods to interact with links table.
 type Repository struct {
 	db *dbpg.DB
@@ -28,31 +42,38 @@ func NewRepository(db *dbpg.DB) *Repository {
 // CreateLink inserts a new link into the database and returns its ID.
 func (r *Repository) CreateLink(ctx context.Context, link model.Link) (model.Link, error) {
 	query := `
-		INSERT INTO links (url, alias)
-		VALUES ($1, $2)
-		RETURNING id, alias, created_at;
+		INSERT INTO links (
+		    workspace_id, domain, url, alias, owner_id, redirect_code, redirect_mode, tracking_pixels,
+		    query_passthrough, path_passthrough, rules, variants, privacy_mode, expires_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING ` + linkColumns + `;
     `
 
-	err := r.db.QueryRowContext(ctx, query, link.URL, link.Alias).Scan(&link.ID, &link.Alias, &link.CreatedAt)
+	res, err := scanLink(r.db.QueryRowContext(
+		ctx, query, link.WorkspaceID, link.Domain, link.URL, link.Alias, link.OwnerID,
+		link.RedirectCode, link.RedirectMode, pq.StringArray(nonNil(link.TrackingPixels)),
+		link.QueryPassthrough, link.PathPassthrough, jsonb{link.Rules}, jsonb{link.Variants}, link.PrivacyMode,
+		utcOrNil(link.ExpiresAt),
+	))
 	if err != nil {
 		return model.Link{}, fmt.Errorf("insert link: %w", err)
 	}
 
-	return link, nil
+	return res, nil
 }
 
-// GetLinkByAlias retrieves the link by its alias.
-func (r *Repository) GetLinkByAlias(ctx context.Context, alias string) (model.Link, error) {
+// GetLinkByAlias retrieves the link identified by the key. It reads from the master, so links
+// are seen right after they are updated, and a stale link is never cached in place of the
+// invalidated one.
+func (r *Repository) GetLinkByAlias(ctx context.Context, key model.LinkKey) (model.Link, error) {
 	query := `
-		SELECT id, url, alias
+		SELECT ` + linkColumns + `
 		FROM links
-		WHERE alias = $1;
+		WHERE workspace_id = $1 AND domain = $2 AND alias = $3 AND ($4::uuid IS NULL OR owner_id = $4);
     `
 
-	var link model.Link
-	err := r.db.QueryRowContext(
-		ctx, query, alias,
-	).Scan(&link.ID, &link.URL, &link.Alias)
+	link, err := scanLink(r.db.Master.QueryRowContext(ctx, query, key.WorkspaceID, key.Domain, key.Alias, key.OwnerID))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return model.Link{}, ErrAliasNotFound
@@ -63,3 +84,354 @@ func (r *Repository) GetLinkByAlias(ctx context.Context, alias string) (model.Li
 
 	return link, nil
 }
+
+// UpdateLink applies a partial update to the link identified by the key and returns the updated link.
+func (r *Repository) UpdateLink(ctx context.Context, key model.LinkKey, upd model.LinkUpdate) (model.Link, error) {
+	query := `
+		UPDATE links
+		SET url               = COALESCE($4, url),
+		    enabled           = COALESCE($5, enabled),
+		    redirect_code     = COALESCE($6, redirect_code),
+		    redirect_mode     = COALESCE($7, redirect_mode),
+		    tracking_pixels   = COALESCE($8, tracking_pixels),
+		    query_passthrough = COALESCE($9, query_passthrough),
+		    path_passthrough  = COALESCE($10, path_passthrough),
+		    rules             = COALESCE($11, rules),
+		    variants          = COALESCE($12, variants),
+		    privacy_mode      = COALESCE($13, privacy_mode),
+		    updated_at        = NOW()
+		WHERE workspace_id = $1 AND domain = $2 AND alias = $3 AND ($14::uuid IS NULL OR owner_id = $14)
+		RETURNING ` + linkColumns + `;
+    `
+
+	var pixels, rules, variants interface{}
+	if upd.TrackingPixels != nil {
+		pixels = pq.StringArray(nonNil(*upd.TrackingPixels))
+	}
+	if upd.Rules != nil {
+		rules = jsonb{*upd.Rules}
+	}
+	if upd.Variants != nil {
+		variants = jsonb{*upd.Variants}
+	}
+
+	// Read and write on master to avoid replication lag.
+	link, err := scanLink(r.db.Master.QueryRowContext(
+		ctx, query, key.WorkspaceID, key.Domain, key.Alias, upd.URL, upd.Enabled,
+		upd.RedirectCode, upd.RedirectMode, pixels, upd.QueryPassthrough, upd.PathPassthrough, rules,
+		variants, upd.PrivacyMode, key.OwnerID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Link{}, ErrAliasNotFound
+		}
+
+		return model.Link{}, fmt.Errorf("update link: %w", err)
+	}
+
+	return link, nil
+}
+
+// DeleteLink deletes the link identified by the key together with its analytics.
+func (r *Repository) DeleteLink(ctx context.Context, key model.LinkKey) error {
+	query := `
+		DELETE FROM links
+		WHERE workspace_id = $1 AND domain = $2 AND alias = $3 AND ($4::uuid IS NULL OR owner_id = $4);
+    `
+
+	res, err := r.db.ExecContext(ctx, query, key.WorkspaceID, key.Domain, key.Alias, key.OwnerID)
+	if err != nil {
+		return fmt.Errorf("delete link: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get deleted links count: %w", err)
+	}
+
+	if n == 0 {
+		return ErrAliasNotFound
+	}
+
+	return nil
+}
+
+// ClaimLinks sets the owner of links of the workspace that have none, e.g. links created
+// before API keys existed, limited to the aliases unless none are given. It returns keys of
+// the claimed links.
+func (r *Repository) ClaimLinks(
+	ctx context.Context, workspaceID, ownerID uuid.UUID, aliases []string,
+) ([]model.LinkKey, error) {
+	query := `
+		UPDATE links
+		SET owner_id   = $2,
+		    updated_at = NOW()
+		WHERE workspace_id = $1 AND owner_id IS NULL AND (cardinality($3::text[]) = 0 OR alias = ANY($3))
+		RETURNING domain, alias;
+    `
+
+	rows, err := r.db.Master.QueryContext(ctx, query, workspaceID, ownerID, pq.StringArray(nonNil(aliases)))
+	if err != nil {
+		return nil, fmt.Errorf("claim links: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []model.LinkKey
+	for rows.Next() {
+		key := model.LinkKey{WorkspaceID: workspaceID}
+
+		if err := rows.Scan(&key.Domain, &key.Alias); err != nil {
+			return nil, fmt.Errorf("scan claimed link: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate claimed links: %w", err)
+	}
+
+	return keys, nil
+}
+
+// ListLinks returns links matching the filter ordered by filter.Sort, starting after the cursor.
+// It returns at most filter.Limit links.
+func (r *Repository) ListLinks(ctx context.Context, filter model.LinkFilter, after *model.LinkCursor) ([]model.LinkListItem, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	// arg appends a query argument and returns its placeholder.
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conds = append(conds, fmt.Sprintf(`l.workspace_id = %s`, arg(filter.WorkspaceID)))
+
+	if filter.Domain != nil {
+		conds = append(conds, fmt.Sprintf(`l.domain = %s`, arg(*filter.Domain)))
+	}
+	if filter.OwnerID != nil {
+		conds = append(conds, fmt.Sprintf(`l.owner_id = %s`, arg(*filter.OwnerID)))
+	}
+	if filter.Host != "" {
+		conds = append(conds, fmt.Sprintf(
+			`LOWER(SUBSTRING(l.url FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/?#]*@)?([^:/?#]+)')) = LOWER(%s)`,
+			arg(filter.Host),
+		))
+	}
+	if filter.AliasPrefix != "" {
+		conds = append(conds, fmt.Sprintf(`l.alias LIKE %s`, arg(escapeLike(filter.AliasPrefix)+"%")))
+	}
+	if filter.CreatedFrom != nil {
+		conds = append(conds, fmt.Sprintf(`l.created_at >= %s`, arg(filter.CreatedFrom.UTC())))
+	}
+	if filter.CreatedTo != nil {
+		conds = append(conds, fmt.Sprintf(`l.created_at < %s`, arg(filter.CreatedTo.UTC())))
+	}
+	if filter.Query != "" {
+		conds = append(conds, fmt.Sprintf(`l.url ILIKE %s`, arg("%"+escapeLike(filter.Query)+"%")))
+	}
+
+	// Keyset pagination over (sort key, id).
+	sortColumn := "l.created_at"
+	if filter.Sort == model.LinkSortClicks {
+		sortColumn = "c.clicks"
+	}
+
+	cmp, order := ">", "ASC"
+	if filter.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	if after != nil {
+		var key interface{} = after.CreatedAt.UTC()
+		if filter.Sort == model.LinkSortClicks {
+			key = after.Clicks
+		}
+		conds = append(conds, fmt.Sprintf(`(%s, l.id) %s (%s, %s)`, sortColumn, cmp, arg(key), arg(after.ID)))
+	}
+
+	// Clicks are summed from the daily rollup rather than counted from raw clicks,
+	// so listing and sorting by clicks do not scan the analytics partitions.
+	query := `
+		SELECT l.` + strings.ReplaceAll(linkColumns, ", ", ", l.") + `, c.clicks
+		FROM links l
+		LEFT JOIN LATERAL (
+		    SELECT COALESCE(SUM(d.clicks), 0) AS clicks
+		    FROM analytics_daily d
+		    WHERE d.link_id = l.id AND d.dimension = 'total' AND NOT d.is_bot
+		) c ON TRUE
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY ` + sortColumn + ` ` + order + `, l.id ` + order + `
+		LIMIT ` + arg(filter.Limit) + `;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query links: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]model.LinkListItem, 0, filter.Limit)
+	for rows.Next() {
+		var item model.LinkListItem
+
+		err := rows.Scan(append(linkFields(&item.Link), &item.Clicks)...)
+		if err != nil {
+			return nil, fmt.Errorf("scan link: %w", err)
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate links: %w", err)
+	}
+
+	return items, nil
+}
+
+// CountDomainLinks returns the number of links of the workspace served on the domain.
+func (r *Repository) CountDomainLinks(ctx context.Context, workspaceID uuid.UUID, domain string) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM links WHERE workspace_id = $1 AND domain = $2;`
+
+	if err := r.db.QueryRowContext(ctx, query, workspaceID, domain).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count domain links: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteExpiredLinks deletes at most limit links that expired before the cutoff
+// and returns the number of deleted rows.
+func (r *Repository) DeleteExpiredLinks(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM links
+		WHERE id IN (
+		    SELECT id
+		    FROM links
+		    WHERE expires_at <= $1
+		    LIMIT $2
+		);
+    `
+
+	res, err := r.db.ExecContext(ctx, query, cutoff.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired links: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get deleted links count: %w", err)
+	}
+
+	return n, nil
+}
+
+// ArchiveExpiredLinks marks at most limit links that expired before the cutoff
+// as archived and returns the number of archived rows.
+func (r *Repository) ArchiveExpiredLinks(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE links
+		SET archived_at = NOW()
+		WHERE id IN (
+		    SELECT id
+		    FROM links
+		    WHERE expires_at <= $1 AND archived_at IS NULL
+		    LIMIT $2
+		);
+    `
+
+	res, err := r.db.ExecContext(ctx, query, cutoff.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("archive expired links: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get archived links count: %w", err)
+	}
+
+	return n, nil
+}
+
+// scanLink scans a row selected with linkColumns into a Link.
+func scanLink(row scanner) (model.Link, error) {
+	var link model.Link
+	err := row.Scan(linkFields(&link)...)
+	return link, err
+}
+
+// linkFields returns scan destinations for linkColumns.
+func linkFields(link *model.Link) []interface{} {
+	return []interface{}{
+		&link.ID, &link.WorkspaceID, &link.Domain, &link.URL, &link.Alias, &link.OwnerID, &link.Enabled,
+		&link.RedirectCode, &link.RedirectMode, (*pq.StringArray)(&link.TrackingPixels),
+		&link.QueryPassthrough, &link.PathPassthrough, jsonb{&link.Rules}, jsonb{&link.Variants}, &link.PrivacyMode,
+		&link.ExpiresAt, &link.ArchivedAt, &link.CreatedAt, &link.UpdatedAt,
+	}
+}
+
+// escapeLike escapes LIKE pattern metacharacters in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// jsonb stores v in a JSONB column and scans the column into v, which must be a pointer.
+// Nil slices are stored as empty JSON arrays.
+type jsonb struct {
+	v interface{}
+}
+
+// Value implements driver.Valuer.
+func (j jsonb) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b) == "null" {
+		return "[]", nil
+	}
+
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (j jsonb) Scan(src interface{}) error {
+	switch b := src.(type) {
+	case []byte:
+		return json.Unmarshal(b, j.v)
+	case string:
+		return json.Unmarshal([]byte(b), j.v)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("unsupported jsonb source %T", src)
+	}
+}
+
+// nonNil returns an empty slice instead of nil, since nil arrays are stored as NULL.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
+
+// utcOrNil converts an optional timestamp to UTC, since links table stores
+// timestamps without time zone.
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC()
+	return &u
+}
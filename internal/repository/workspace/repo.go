@@ -0,0 +1,235 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrMemberNotFound    = errors.New("workspace member not found")
+	ErrSlugTaken         = errors.New("workspace slug already taken")
+)
+
+// uniqueViolation is the PostgreSQL error code of unique constraint violations.
+const uniqueViolation = "23505"
+
+// Repository provides methods to interact with workspaces and workspace_members tables.
+type Repository struct {
+	db *dbpg.DB
+}
+
+// NewRepository creates a new workspace repository.
+func NewRepository(db *dbpg.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateWorkspace inserts a new workspace together with its first owner and returns it.
+func (r *Repository) CreateWorkspace(ctx context.Context, ws model.Workspace, ownerID uuid.UUID) (model.Workspace, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Workspace{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+		INSERT INTO workspaces (slug, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at;
+    `
+
+	err = tx.QueryRowContext(ctx, query, ws.Slug, ws.Name).Scan(&ws.ID, &ws.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return model.Workspace{}, ErrSlugTaken
+		}
+
+		return model.Workspace{}, fmt.Errorf("insert workspace: %w", err)
+	}
+
+	query = `
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3);
+    `
+
+	if _, err := tx.ExecContext(ctx, query, ws.ID, ownerID, model.RoleOwner); err != nil {
+		return model.Workspace{}, fmt.Errorf("insert workspace owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Workspace{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return ws, nil
+}
+
+// GetWorkspaceBySlug retrieves the workspace by its slug.
+func (r *Repository) GetWorkspaceBySlug(ctx context.Context, slug string) (model.Workspace, error) {
+	query := `
+		SELECT id, slug, name, owner_scoped, created_at
+		FROM workspaces
+		WHERE slug = $1;
+    `
+
+	var ws model.Workspace
+	err := r.db.QueryRowContext(ctx, query, slug).Scan(&ws.ID, &ws.Slug, &ws.Name, &ws.OwnerScoped, &ws.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Workspace{}, ErrWorkspaceNotFound
+		}
+
+		return model.Workspace{}, fmt.Errorf("get workspace by slug: %w", err)
+	}
+
+	return ws, nil
+}
+
+// ListUserWorkspaces returns all workspaces the user is a member of, together with the user's role.
+func (r *Repository) ListUserWorkspaces(ctx context.Context, userID uuid.UUID) ([]model.UserWorkspace, error) {
+	query := `
+		SELECT w.id, w.slug, w.name, w.owner_scoped, w.created_at, m.role
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.slug;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query user workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.UserWorkspace, 0)
+	for rows.Next() {
+		var ws model.UserWorkspace
+
+		if err := rows.Scan(&ws.ID, &ws.Slug, &ws.Name, &ws.OwnerScoped, &ws.CreatedAt, &ws.Role); err != nil {
+			return nil, fmt.Errorf("scan user workspace: %w", err)
+		}
+
+		result = append(result, ws)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate user workspaces: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetMember retrieves membership of the user in the workspace.
+func (r *Repository) GetMember(ctx context.Context, workspaceID, userID uuid.UUID) (model.WorkspaceMember, error) {
+	query := `
+		SELECT workspace_id, user_id, role, created_at
+		FROM workspace_members
+		WHERE workspace_id = $1 AND user_id = $2;
+    `
+
+	var m model.WorkspaceMember
+	err := r.db.QueryRowContext(ctx, query, workspaceID, userID).Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.WorkspaceMember{}, ErrMemberNotFound
+		}
+
+		return model.WorkspaceMember{}, fmt.Errorf("get workspace member: %w", err)
+	}
+
+	return m, nil
+}
+
+// ListMembers returns all members of the workspace.
+func (r *Repository) ListMembers(ctx context.Context, workspaceID uuid.UUID) ([]model.WorkspaceMember, error) {
+	query := `
+		SELECT workspace_id, user_id, role, created_at
+		FROM workspace_members
+		WHERE workspace_id = $1
+		ORDER BY created_at;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]model.WorkspaceMember, 0)
+	for rows.Next() {
+		var m model.WorkspaceMember
+
+		if err := rows.Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan workspace member: %w", err)
+		}
+
+		members = append(members, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate workspace members: %w", err)
+	}
+
+	return members, nil
+}
+
+// UpsertMember adds the user to the workspace or changes the user's role.
+func (r *Repository) UpsertMember(ctx context.Context, m model.WorkspaceMember) (model.WorkspaceMember, error) {
+	query := `
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING created_at;
+    `
+
+	err := r.db.Master.QueryRowContext(ctx, query, m.WorkspaceID, m.UserID, m.Role).Scan(&m.CreatedAt)
+	if err != nil {
+		return model.WorkspaceMember{}, fmt.Errorf("upsert workspace member: %w", err)
+	}
+
+	return m, nil
+}
+
+// DeleteMember removes the user from the workspace.
+func (r *Repository) DeleteMember(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	query := `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;`
+
+	res, err := r.db.ExecContext(ctx, query, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("delete workspace member: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get deleted workspace members count: %w", err)
+	}
+
+	if n == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// CountOwners returns the number of owners of the workspace.
+func (r *Repository) CountOwners(ctx context.Context, workspaceID uuid.UUID) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = 'owner';`
+
+	if err := r.db.QueryRowContext(ctx, query, workspaceID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count workspace owners: %w", err)
+	}
+
+	return count, nil
+}
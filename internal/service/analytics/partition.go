@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Retention modes supported by ExpirePartitions.
+const (
+	RetentionModeArchive = "archive"
+	RetentionModeDrop    = "drop"
+)
+
+// ErrUnknownRetentionMode is returned when ExpirePartitions gets an unsupported mode.
+var ErrUnknownRetentionMode = errors.New("unknown retention mode")
+
+// EnsurePartitions creates missing monthly partitions of clicks from the month of now
+// through the given number of months ahead, and of months of clicks that landed in the
+// default partition, e.g. redelivered old events, so that retention applies to them.
+func (s *Service) EnsurePartitions(ctx context.Context, now time.Time, ahead int) error {
+	month := now.UTC()
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	months, err := s.repo.ListDefaultMonths(ctx)
+	if err != nil {
+		return fmt.Errorf("list default partition months: %w", err)
+	}
+
+	for i := 0; i <= ahead; i++ {
+		months = append(months, month.AddDate(0, i, 0))
+	}
+
+	for _, m := range months {
+		if err := s.repo.CreatePartition(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExpirePartitions archives or drops, depending on mode, monthly partitions of clicks
+// that started at or before the cutoff, so no click is kept longer than the time between
+// the cutoff and now. It returns months of the expired partitions.
+func (s *Service) ExpirePartitions(ctx context.Context, mode string, cutoff time.Time) ([]time.Time, error) {
+	var expire func(ctx context.Context, month time.Time) error
+	switch mode {
+	case RetentionModeArchive:
+		expire = s.repo.ArchivePartition
+	case RetentionModeDrop:
+		expire = s.repo.DropPartition
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRetentionMode, mode)
+	}
+
+	months, err := s.repo.ListPartitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+
+	var expired []time.Time
+	for _, month := range months {
+		if month.After(cutoff) {
+			break // partitions are listed in ascending order
+		}
+
+		if err := expire(ctx, month); err != nil {
+			return expired, err
+		}
+
+		expired = append(expired, month)
+	}
+
+	return expired, nil
+}
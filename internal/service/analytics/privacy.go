@@ -0,0 +1,92 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// Prefix lengths of networks IPs are truncated to by model.PrivacyModeTruncate.
+const (
+	truncatedIPv4Bits = 24
+	truncatedIPv6Bits = 48
+)
+
+// anonymize strips personal fields of the event after it was enriched: all of them if the
+// visitor opted out of tracking, otherwise only the IP, as told by the privacy mode of the
+// event. Unknown modes truncate the IP.
+func (s *Service) anonymize(ctx context.Context, event *model.Analytics) {
+	if event.DoNotTrack {
+		event.IP = ""
+		event.UserAgent = ""
+		event.Referrer = ""
+		event.VisitorID = ""
+		event.Region = ""
+		event.City = ""
+		event.ASN = 0
+		event.ASOrg = ""
+		return
+	}
+
+	switch event.PrivacyMode {
+	case model.PrivacyModeFull:
+	case model.PrivacyModeHash:
+		at := event.CreatedAt
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		hash, err := s.visitors.HashIP(ctx, event.IP, at)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("alias", event.Alias).Msg("failed to hash ip")
+		}
+		event.IP, event.IPHash = "", hash
+	default:
+		event.IP = truncateIP(event.IP)
+	}
+}
+
+// truncateIP returns the address of the IPv4 /24 or IPv6 /48 network of the IP,
+// or an empty string if it is not a valid IP.
+func truncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(truncatedIPv4Bits, 32)).String()
+	}
+
+	return parsed.Mask(net.CIDRMask(truncatedIPv6Bits, 128)).String()
+}
+
+// EraseIP deletes clicks from the client IP, archived ones included, for a data subject
+// erasure request and returns the number of deleted clicks. Clicks saved with a hash of the
+// IP are found only while the salts of the hashes are kept, which is today and yesterday;
+// truncated IPs are not attributable to a single visitor and are kept.
+func (s *Service) EraseIP(ctx context.Context, ip string) (int64, error) {
+	now := time.Now()
+
+	var hashes []string
+	for _, at := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		hash, err := s.visitors.HashIP(ctx, ip, at)
+		if err != nil {
+			return 0, fmt.Errorf("hash ip: %w", err)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	n, err := s.repo.DeleteAnalyticsByIP(ctx, ip, hashes)
+	if err != nil {
+		return 0, fmt.Errorf("delete analytics: %w", err)
+	}
+
+	return n, nil
+}
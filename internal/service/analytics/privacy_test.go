@@ -0,0 +1,28 @@
+package analytics
+
+import "testing"
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{"ipv4", "203.0.113.77", "203.0.113.0"},
+		{"ipv4 network", "203.0.113.0", "203.0.113.0"},
+		{"ipv4-mapped ipv6", "::ffff:203.0.113.77", "203.0.113.0"},
+		{"ipv6", "2001:db8:abcd:12:34::1", "2001:db8:abcd::"},
+		{"ipv6 loopback", "::1", "::"},
+		{"empty", "", ""},
+		{"invalid", "not-an-ip", ""},
+		{"with port", "203.0.113.77:8080", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateIP(tt.ip); got != tt.want {
+				t.Errorf("truncateIP(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,26 +3,93 @@ package analytics
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
 
+	"github.com/aliskhannn/url-shortener/internal/geoip"
 	"github.com/aliskhannn/url-shortener/internal/model"
 )
 
 // analyticsRepository defines the interface for link analytics persistence operations.
 type analyticsRepository interface {
-	SaveAnalytics(ctx context.Context, event model.Analytics) (uuid.UUID, error)
-	CountClicks(ctx context.Context, alias string) (int, error)
-	GetClicksByDay(ctx context.Context, alias string) (map[string]int, error)
-	GetClicksByUserAgent(ctx context.Context, alias string) (map[string]int, error)
+	SaveAnalyticsBatch(ctx context.Context, events []model.Analytics) ([]model.Analytics, error)
+	CountClicks(ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter) (int, error)
+	CountVisitors(ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter) (int, error)
+	GetVisitorIDs(ctx context.Context, workspaceID, linkID uuid.UUID, after string, limit int) ([]string, error)
+	GetClickSeries(
+		ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter,
+		granularity model.Granularity, timezone string,
+	) ([]model.TimeBucket, error)
+	GetClicksByUserAgent(ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter) (map[string]int, error)
+	GetClicksBySource(ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter) (map[string]int, error)
+	GetTopValues(
+		ctx context.Context, workspaceID, linkID uuid.UUID, f model.ClickFilter, dimension model.Dimension, limit int,
+	) ([]model.DimensionCount, error)
+	CreatePartition(ctx context.Context, month time.Time) error
+	ListDefaultMonths(ctx context.Context) ([]time.Time, error)
+	ListPartitions(ctx context.Context) ([]time.Time, error)
+	DropPartition(ctx context.Context, month time.Time) error
+	ArchivePartition(ctx context.Context, month time.Time) error
+	DeleteAnalyticsByIP(ctx context.Context, ip string, hashes []string) (int64, error)
 }
 
+// geoLocator defines the interface for resolving locations of client IPs.
+type geoLocator interface {
+	Lookup(ip string) geoip.Location
+}
+
+// visitorStore defines the interface for identifying and counting unique visitors.
+type visitorStore interface {
+	ID(ctx context.Context, ip, userAgent string, at time.Time) (string, error)
+	HashIP(ctx context.Context, ip string, at time.Time) (string, error)
+	Add(ctx context.Context, linkID uuid.UUID, ids ...string) error
+	MarkComplete(ctx context.Context, linkID uuid.UUID) error
+	Count(ctx context.Context, linkID uuid.UUID) (int, bool, error)
+}
+
+// clickPublisher defines the interface for publishing saved clicks to real-time subscribers.
+type clickPublisher interface {
+	Publish(ctx context.Context, events []model.Analytics) error
+}
+
+// visitorBatchSize is the number of visitor IDs restored to Redis at once.
+const visitorBatchSize = 1000
+
+// restoreTimeout bounds the background restore of visitors of a link to Redis.
+const restoreTimeout = 10 * time.Minute
+
+// Numbers of entries in summary breakdowns.
+const (
+	topValuesLimit = 10  // top referrers, campaigns and languages
+	countriesLimit = 250 // all countries
+	citiesLimit    = 100 // most clicked cities
+	rulesLimit     = 100 // all redirect rules
+	variantsLimit  = 100 // all A/B variants
+	devicesLimit   = 10  // all device types
+	osLimit        = 10  // all OS families
+	browsersLimit  = 20  // most clicked browsers
+	trafficLimit   = 2   // bots and humans
+)
+
+// maxSeriesBuckets bounds the number of buckets of a summary time series.
+const maxSeriesBuckets = 10000
+
+// summaryCacheTTL is how long summaries stay cached, and so how long new clicks may
+// be missing from them.
+const summaryCacheTTL = time.Minute
+
+// ErrRangeTooLarge is returned when the time series would exceed maxSeriesBuckets.
+var ErrRangeTooLarge = errors.New("time range has too many buckets for the granularity")
+
 // cache defines the interface for caching link analytics.
 type cache interface {
-	SetWithRetry(ctx context.Context, strategy retry.Strategy, key string, value interface{}) error
+	SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	GetWithRetry(ctx context.Context, strategy retry.Strategy, key string) (string, error)
 }
 
@@ -30,86 +97,371 @@ type cache interface {
 type Service struct {
 	repo  analyticsRepository
 	cache cache
+	geo   geoLocator
+
+	visitors  visitorStore
+	restoring sync.Map // IDs of links whose visitors are being restored to Redis
+	live      clickPublisher
 }
 
-// NewService creates a new Service instance with repository and cache.
-func NewService(repo analyticsRepository, cache cache) *Service {
-	return &Service{repo: repo, cache: cache}
+// NewService creates a new Service instance with repository, cache, geo locator, visitor store
+// and publisher of live clicks.
+func NewService(
+	repo analyticsRepository, cache cache, geo geoLocator, visitors visitorStore, live clickPublisher,
+) *Service {
+	return &Service{repo: repo, cache: cache, geo: geo, visitors: visitors, live: live}
 }
 
-type SummaryOfAnalytics struct {
-	Alias       string         `json:"alias"`
-	TotalClicks int            `json:"total_clicks"`
-	Daily       map[string]int `json:"daily"`      // clicks per day
-	UserAgent   map[string]int `json:"user_agent"` // clicks per User_Agent
+// Query selects the clicks of an analytics summary and the layout of its time series.
+type Query struct {
+	Filter      model.ClickFilter // clicks to summarize
+	Granularity model.Granularity // size of time series buckets
+	Location    *time.Location    // time zone of time series buckets, UTC if nil
+	UserAgents  bool              // include clicks per raw user agent
 }
 
-// SaveAnalytics save a link analytics and caches them.
-func (s *Service) SaveAnalytics(ctx context.Context, strategy retry.Strategy, event model.Analytics) (uuid.UUID, error) {
-	id, err := s.repo.SaveAnalytics(ctx, event)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("save analytics: %w", err)
+// DefaultQuery returns the query of all human clicks with daily buckets in UTC.
+func DefaultQuery() Query {
+	return Query{Granularity: model.GranularityDay, Location: time.UTC}
+}
+
+// location returns the time zone of the query.
+func (q Query) location() *time.Location {
+	if q.Location == nil {
+		return time.UTC
 	}
 
-	event.ID = id
+	return q.Location
+}
 
-	go func() {
-		summary, err := s.GetAnalyticsSummary(ctx, strategy, event.Alias)
-		if err != nil {
-			zlog.Logger.Error().Err(err).Str("alias", event.Alias).Msg("failed to get analytics summary for cache")
-			return
+type SummaryOfAnalytics struct {
+	Alias          string             `json:"alias"`
+	TotalClicks    int                `json:"total_clicks"`
+	UniqueVisitors int                `json:"unique_visitors"`      // distinct visitors per UTC day summed over days, estimated for all-time summaries
+	Granularity    model.Granularity  `json:"granularity"`          // size of series buckets
+	Timezone       string             `json:"timezone"`             // time zone of series buckets
+	Series         []model.TimeBucket `json:"series"`               // clicks per bucket, oldest first and zero-filled
+	UserAgent      map[string]int     `json:"user_agent,omitempty"` // clicks per User_Agent, only if requested
+	Source         map[string]int     `json:"source"`               // clicks per traffic source, e.g. "qr" or "direct"
+
+	TopReferrers []model.DimensionCount `json:"top_referrers"` // most clicked referring domains
+	TopCampaigns []model.DimensionCount `json:"top_campaigns"` // most clicked utm_campaign values
+	TopLanguages []model.DimensionCount `json:"top_languages"` // most clicked visitor locales
+	Countries    []model.DimensionCount `json:"countries"`     // clicks per country
+	Cities       []model.DimensionCount `json:"cities"`        // most clicked cities
+	Rules        []model.DimensionCount `json:"rules"`         // clicks per matched redirect rule
+	Variants     []model.DimensionCount `json:"variants"`      // clicks per A/B variant
+	Devices      []model.DimensionCount `json:"devices"`       // clicks per device type
+	OSFamilies   []model.DimensionCount `json:"os_families"`   // clicks per OS family
+	Browsers     []model.DimensionCount `json:"browsers"`      // most clicked browsers
+	Traffic      []model.DimensionCount `json:"traffic"`       // bot vs human clicks
+}
+
+// PrepareClick enriches a link analytics event with the visitor location and ID and
+// anonymizes it by its privacy mode. Events are prepared by the ingest workers before they
+// are saved or appended to the stream, so raw IPs of visitors are never stored.
+func (s *Service) PrepareClick(ctx context.Context, event model.Analytics) model.Analytics {
+	s.enrich(ctx, &event)
+	s.anonymize(ctx, &event)
+
+	return event
+}
+
+// SaveAnalyticsBatch saves link analytics events prepared by PrepareClick, counts the
+// human visitors and publishes the clicks saved for the first time to real-time subscribers.
+func (s *Service) SaveAnalyticsBatch(ctx context.Context, strategy retry.Strategy, events []model.Analytics) error {
+	visitors := make(map[uuid.UUID][]string)
+	for _, e := range events {
+		if e.VisitorID != "" && !e.IsBot {
+			visitors[e.LinkID] = append(visitors[e.LinkID], e.VisitorID)
 		}
+	}
 
-		if b, err := json.Marshal(summary); err == nil {
-			if err := s.cache.SetWithRetry(ctx, strategy, "analytics:"+event.Alias, string(b)); err != nil {
-				zlog.Logger.Error().Err(err).Str("alias", event.Alias).Msg("failed to cache aggregated analytics")
-			}
+	// A batch is saved in a transaction skipping events saved before, so retrying it
+	// cannot duplicate events. Only the saved events are published.
+	var saved []model.Analytics
+	err := retry.Do(func() error {
+		var err error
+		saved, err = s.repo.SaveAnalyticsBatch(ctx, events)
+		return err
+	}, strategy)
+	if err != nil {
+		return fmt.Errorf("save analytics: %w", err)
+	}
+
+	for linkID, ids := range visitors {
+		if err := s.visitors.Add(ctx, linkID, ids...); err != nil {
+			zlog.Logger.Error().Err(err).Str("link_id", linkID.String()).Msg("failed to count visitors")
 		}
+	}
 
-		zlog.Logger.Info().Str("alias", event.Alias).Msg("saved analytics")
-	}()
+	if err := s.live.Publish(ctx, saved); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to publish live clicks")
+	}
 
-	return id, nil
+	return nil
 }
 
-// GetAnalyticsSummary retrieves aggregated analytics for a short link.
-func (s *Service) GetAnalyticsSummary(ctx context.Context, strategy retry.Strategy, alias string) (*SummaryOfAnalytics, error) {
+// GetAnalyticsSummary retrieves aggregated analytics of the query for a short link.
+func (s *Service) GetAnalyticsSummary(
+	ctx context.Context, strategy retry.Strategy, link model.Link, q Query,
+) (*SummaryOfAnalytics, error) {
+	key := summaryCacheKey(link, q)
+
 	// Check cache first.
-	if str, err := s.cache.GetWithRetry(ctx, strategy, "analytics:"+alias); err != nil {
+	if str, err := s.cache.GetWithRetry(ctx, strategy, key); err == nil {
 		var summary SummaryOfAnalytics
 		if err := json.Unmarshal([]byte(str), &summary); err == nil {
 			return &summary, nil // cache hit
 		}
 	}
 
-	total, err := s.repo.CountClicks(ctx, alias)
+	summary, err := s.summarize(ctx, link, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save to cache.
+	if b, err := json.Marshal(summary); err == nil {
+		if err := s.cache.SetWithExpiration(ctx, key, string(b), summaryCacheTTL); err != nil {
+			zlog.Logger.Error().Err(err).Str("alias", link.Alias).Msg("failed to cache summary analytics")
+		}
+	}
+
+	return summary, nil
+}
+
+// summarize aggregates analytics of the query for a short link.
+func (s *Service) summarize(ctx context.Context, link model.Link, q Query) (*SummaryOfAnalytics, error) {
+	total, err := s.repo.CountClicks(ctx, link.WorkspaceID, link.ID, q.Filter)
 	if err != nil {
 		return nil, fmt.Errorf("count clicks: %w", err)
 	}
 
-	daily, err := s.repo.GetClicksByDay(ctx, alias)
+	visitors, err := s.uniqueVisitors(ctx, link, q)
 	if err != nil {
-		return nil, fmt.Errorf("get clicks by day: %w", err)
+		return nil, err
 	}
 
-	ua, err := s.repo.GetClicksByUserAgent(ctx, alias)
+	series, err := s.series(ctx, link, q)
 	if err != nil {
-		return nil, fmt.Errorf("get clicks by user agent: %w", err)
+		return nil, err
+	}
+
+	source, err := s.repo.GetClicksBySource(ctx, link.WorkspaceID, link.ID, q.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("get clicks by source: %w", err)
 	}
 
 	summary := &SummaryOfAnalytics{
-		Alias:       alias,
-		TotalClicks: total,
-		Daily:       daily,
-		UserAgent:   ua,
+		Alias:          link.Alias,
+		TotalClicks:    total,
+		UniqueVisitors: visitors,
+		Granularity:    q.Granularity,
+		Timezone:       q.location().String(),
+		Series:         series,
+		Source:         source,
 	}
 
-	// Save to cache.
-	if b, err := json.Marshal(summary); err == nil {
-		if err := s.cache.SetWithRetry(ctx, strategy, "analytics:"+alias, string(b)); err != nil {
-			zlog.Logger.Error().Err(err).Str("alias", alias).Msg("failed to cache summary analytics")
+	tops := []struct {
+		dimension model.Dimension
+		limit     int
+		dst       *[]model.DimensionCount
+	}{
+		{model.DimensionReferrer, topValuesLimit, &summary.TopReferrers},
+		{model.DimensionCampaign, topValuesLimit, &summary.TopCampaigns},
+		{model.DimensionLanguage, topValuesLimit, &summary.TopLanguages},
+		{model.DimensionCountry, countriesLimit, &summary.Countries},
+		{model.DimensionCity, citiesLimit, &summary.Cities},
+		{model.DimensionRule, rulesLimit, &summary.Rules},
+		{model.DimensionVariant, variantsLimit, &summary.Variants},
+		{model.DimensionDevice, devicesLimit, &summary.Devices},
+		{model.DimensionOS, osLimit, &summary.OSFamilies},
+		{model.DimensionBrowser, browsersLimit, &summary.Browsers},
+		{model.DimensionTraffic, trafficLimit, &summary.Traffic},
+	}
+
+	for _, t := range tops {
+		values, err := s.repo.GetTopValues(ctx, link.WorkspaceID, link.ID, q.Filter, t.dimension, t.limit)
+		if err != nil {
+			return nil, fmt.Errorf("get top %s values: %w", t.dimension, err)
+		}
+		*t.dst = values
+	}
+
+	// Raw user agents are too many to be useful by default.
+	if q.UserAgents {
+		summary.UserAgent, err = s.repo.GetClicksByUserAgent(ctx, link.WorkspaceID, link.ID, q.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("get clicks by user agent: %w", err)
 		}
 	}
 
 	return summary, nil
 }
+
+// uniqueVisitors returns the number of unique visitors of the query. Visitor IDs change
+// every UTC day, so a visitor returning on several days counts once per day.
+//
+// All-time numbers of humans are estimated by the HyperLogLog of the link while it holds
+// all visitors. Otherwise they are counted by Postgres, like numbers of time ranges, and
+// the HyperLogLog is restored in the background.
+func (s *Service) uniqueVisitors(ctx context.Context, link model.Link, q Query) (int, error) {
+	allTime := q.Filter == (model.ClickFilter{})
+	if allTime {
+		n, complete, err := s.visitors.Count(ctx, link.ID)
+		if err == nil && complete {
+			return n, nil
+		}
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("alias", link.Alias).Msg("failed to count visitors in redis")
+			allTime = false // Redis is unavailable, so restoring would fail too
+		}
+	}
+
+	n, err := s.repo.CountVisitors(ctx, link.WorkspaceID, link.ID, q.Filter)
+	if err != nil {
+		return 0, fmt.Errorf("count visitors: %w", err)
+	}
+
+	if allTime && n > 0 {
+		s.restoreVisitorsAsync(ctx, link)
+	}
+
+	return n, nil
+}
+
+// restoreVisitorsAsync restores visitors of the link to its HyperLogLog in the background,
+// unless a restore of the link is already running on this instance.
+func (s *Service) restoreVisitorsAsync(ctx context.Context, link model.Link) {
+	if _, running := s.restoring.LoadOrStore(link.ID, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer s.restoring.Delete(link.ID)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+		defer cancel()
+
+		if err := s.restoreVisitors(ctx, link); err != nil {
+			zlog.Logger.Error().Err(err).Str("alias", link.Alias).Msg("failed to restore visitors in redis")
+		}
+	}()
+}
+
+// restoreVisitors adds all visitor IDs of the link stored in Postgres to its HyperLogLog
+// and marks it complete. Visitors of clicks saved meanwhile are added by SaveAnalyticsBatch.
+func (s *Service) restoreVisitors(ctx context.Context, link model.Link) error {
+	after := ""
+	for {
+		ids, err := s.repo.GetVisitorIDs(ctx, link.WorkspaceID, link.ID, after, visitorBatchSize)
+		if err != nil {
+			return fmt.Errorf("get visitor ids: %w", err)
+		}
+
+		if len(ids) == 0 {
+			return s.visitors.MarkComplete(ctx, link.ID)
+		}
+
+		if err := s.visitors.Add(ctx, link.ID, ids...); err != nil {
+			return err
+		}
+
+		after = ids[len(ids)-1]
+	}
+}
+
+// series returns the click time series of the query, zero-filled from the start of the
+// range (or the first click) to its end (or now).
+func (s *Service) series(ctx context.Context, link model.Link, q Query) ([]model.TimeBucket, error) {
+	loc, g := q.location(), q.Granularity
+
+	clicks, err := s.repo.GetClickSeries(ctx, link.WorkspaceID, link.ID, q.Filter, g, loc.String())
+	if err != nil {
+		return nil, fmt.Errorf("get click series: %w", err)
+	}
+
+	// Buckets are laid out on the wall clock of the time zone, like in the repository.
+	var start time.Time
+	switch {
+	case !q.Filter.From.IsZero():
+		start = g.Truncate(wallClock(q.Filter.From, loc))
+	case len(clicks) > 0:
+		start = clicks[0].Time
+	default:
+		return []model.TimeBucket{}, nil
+	}
+
+	end := g.Truncate(wallClock(time.Now(), loc))
+	if !q.Filter.To.IsZero() {
+		end = g.Truncate(wallClock(q.Filter.To.Add(-time.Nanosecond), loc))
+	}
+	if n := len(clicks); n > 0 && clicks[n-1].Time.After(end) {
+		end = clicks[n-1].Time
+	}
+
+	series := make([]model.TimeBucket, 0)
+	for t, i := start, 0; !t.After(end); t = g.Next(t) {
+		if len(series) == maxSeriesBuckets {
+			return nil, ErrRangeTooLarge
+		}
+
+		b := model.TimeBucket{Time: time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)}
+		for ; i < len(clicks) && !clicks[i].Time.After(t); i++ {
+			if clicks[i].Time.Equal(t) {
+				b.Clicks, b.UniqueVisitors = clicks[i].Clicks, clicks[i].UniqueVisitors
+			}
+		}
+
+		series = append(series, b)
+	}
+
+	return series, nil
+}
+
+// wallClock returns the wall clock time of t in the time zone as a UTC time.
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// enrich sets location fields of the event resolved from its IP and the visitor ID.
+func (s *Service) enrich(ctx context.Context, event *model.Analytics) {
+	at := event.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	id, err := s.visitors.ID(ctx, event.IP, event.UserAgent, at)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("alias", event.Alias).Msg("failed to identify visitor")
+	}
+	event.VisitorID = id
+
+	loc := s.geo.Lookup(event.IP)
+
+	event.Country = loc.Country
+	event.Region = loc.Region
+	event.City = loc.City
+	event.ASN = loc.ASN
+	event.ASOrg = loc.ASOrg
+}
+
+// summaryCacheKey returns the cache key of the link analytics summary of the query.
+func summaryCacheKey(link model.Link, q Query) string {
+	return fmt.Sprintf(
+		"analytics:%s:%s:%s:%d:%d:%t:%t",
+		link.ID, q.Granularity, q.location(), unixOrZero(q.Filter.From), unixOrZero(q.Filter.To),
+		q.Filter.IncludeBots, q.UserAgents,
+	)
+}
+
+// unixOrZero returns t as Unix time, or zero if t is zero.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// seriesRepo returns a fixed click series and fails every other call.
+type seriesRepo struct {
+	analyticsRepository
+	clicks []model.TimeBucket
+}
+
+func (r seriesRepo) GetClickSeries(
+	context.Context, uuid.UUID, uuid.UUID, model.ClickFilter, model.Granularity, string,
+) ([]model.TimeBucket, error) {
+	return r.clicks, nil
+}
+
+// day returns the UTC time of 2025-01-day at hh:00.
+func day(d, hh int) time.Time {
+	return time.Date(2025, time.January, d, hh, 0, 0, 0, time.UTC)
+}
+
+func TestSeries(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   Query
+		clicks  []model.TimeBucket
+		want    []model.TimeBucket
+		wantErr error
+	}{
+		{
+			name:  "zero-filled range",
+			query: Query{Granularity: model.GranularityDay, Filter: model.ClickFilter{From: day(1, 10), To: day(4, 0)}},
+			clicks: []model.TimeBucket{
+				{Time: day(2, 0), Clicks: 3, UniqueVisitors: 2},
+			},
+			want: []model.TimeBucket{
+				{Time: day(1, 0)},
+				{Time: day(2, 0), Clicks: 3, UniqueVisitors: 2},
+				{Time: day(3, 0)},
+			},
+		},
+		{
+			name:  "end bound inside a bucket",
+			query: Query{Granularity: model.GranularityDay, Filter: model.ClickFilter{From: day(1, 0), To: day(2, 12)}},
+			want:  []model.TimeBucket{{Time: day(1, 0)}, {Time: day(2, 0)}},
+		},
+		{
+			name:  "open start from the first click",
+			query: Query{Granularity: model.GranularityHour, Filter: model.ClickFilter{To: day(1, 13)}},
+			clicks: []model.TimeBucket{
+				{Time: day(1, 10), Clicks: 1, UniqueVisitors: 1},
+				{Time: day(1, 12), Clicks: 2, UniqueVisitors: 1},
+			},
+			want: []model.TimeBucket{
+				{Time: day(1, 10), Clicks: 1, UniqueVisitors: 1},
+				{Time: day(1, 11)},
+				{Time: day(1, 12), Clicks: 2, UniqueVisitors: 1},
+			},
+		},
+		{
+			name:  "clicks after the end extend it",
+			query: Query{Granularity: model.GranularityDay, Filter: model.ClickFilter{From: day(1, 0), To: day(2, 0)}},
+			clicks: []model.TimeBucket{
+				{Time: day(3, 0), Clicks: 1, UniqueVisitors: 1},
+			},
+			want: []model.TimeBucket{
+				{Time: day(1, 0)},
+				{Time: day(2, 0)},
+				{Time: day(3, 0), Clicks: 1, UniqueVisitors: 1},
+			},
+		},
+		{
+			name:  "no clicks and no start",
+			query: Query{Granularity: model.GranularityDay, Filter: model.ClickFilter{To: day(3, 0)}},
+			want:  []model.TimeBucket{},
+		},
+		{
+			name: "buckets on the wall clock of the time zone",
+			query: Query{
+				Granularity: model.GranularityDay,
+				Location:    newYork,
+				Filter:      model.ClickFilter{From: day(2, 5), To: day(3, 5)}, // midnight in New York
+			},
+			clicks: []model.TimeBucket{
+				{Time: day(2, 0), Clicks: 4, UniqueVisitors: 3},
+			},
+			want: []model.TimeBucket{
+				{Time: time.Date(2025, time.January, 2, 0, 0, 0, 0, newYork), Clicks: 4, UniqueVisitors: 3},
+			},
+		},
+		{
+			name:    "too many buckets",
+			query:   Query{Granularity: model.GranularityMinute, Filter: model.ClickFilter{From: day(1, 0), To: day(10, 0)}},
+			wantErr: ErrRangeTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{repo: seriesRepo{clicks: tt.clicks}}
+
+			got, err := s.series(context.Background(), model.Link{}, tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("series() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("series() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWallClock(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{"utc", day(1, 10), time.UTC, day(1, 10)},
+		{"east of utc", day(1, 22), time.FixedZone("UTC+3", 3*60*60), day(2, 1)},
+		{"west of utc", day(1, 2), time.FixedZone("UTC-5", -5*60*60), day(0, 21)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wallClock(tt.t, tt.loc); !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("wallClock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+	apikeyrepo "github.com/aliskhannn/url-shortener/internal/repository/apikey"
+)
+
+const (
+	keyPrefix    = "sk_" // prefix of every issued key, makes keys easy to recognize
+	keyBytes     = 32    // number of random bytes in a key
+	prefixLength = 10    // number of leading key characters stored in clear text
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid api key")
+)
+
+// apiKeyRepository defines the interface for API key persistence operations.
+type apiKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error)
+	GetActiveAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error)
+	ListAPIKeys(ctx context.Context, ownerID uuid.UUID) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, ownerID, id uuid.UUID) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+// The Service provides methods for issuing and verifying API keys.
+type Service struct {
+	repo apiKeyRepository
+}
+
+// NewService creates a new Service instance with repository.
+func NewService(repo apiKeyRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// CreateKey issues a new API key for the owner.
+// It returns the stored key and its plaintext value, which is not persisted anywhere.
+func (s *Service) CreateKey(ctx context.Context, ownerID uuid.UUID, name string) (model.APIKey, string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return model.APIKey{}, "", fmt.Errorf("generate api key: %w", err)
+	}
+
+	token := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key, err := s.repo.CreateAPIKey(ctx, model.APIKey{
+		OwnerID: ownerID,
+		Name:    name,
+		Prefix:  token[:prefixLength],
+		Hash:    hashKey(token),
+	})
+	if err != nil {
+		return model.APIKey{}, "", fmt.Errorf("create api key: %w", err)
+	}
+
+	return key, token, nil
+}
+
+// Authenticate verifies the plaintext API key and returns the matching stored key.
+// Unknown and revoked keys are reported with ErrInvalidKey.
+func (s *Service) Authenticate(ctx context.Context, token string) (model.APIKey, error) {
+	if !strings.HasPrefix(token, keyPrefix) {
+		return model.APIKey{}, ErrInvalidKey
+	}
+
+	key, err := s.repo.GetActiveAPIKeyByHash(ctx, hashKey(token))
+	if err != nil {
+		if errors.Is(err, apikeyrepo.ErrKeyNotFound) {
+			return model.APIKey{}, ErrInvalidKey
+		}
+
+		return model.APIKey{}, fmt.Errorf("get api key: %w", err)
+	}
+
+	// Usage tracking is best-effort and must not fail authentication.
+	if err := s.repo.TouchAPIKey(ctx, key.ID); err != nil {
+		zlog.Logger.Error().Err(err).Str("key_id", key.ID.String()).Msg("failed to update api key usage")
+	}
+
+	return key, nil
+}
+
+// ListKeys returns all API keys of the owner.
+func (s *Service) ListKeys(ctx context.Context, ownerID uuid.UUID) ([]model.APIKey, error) {
+	keys, err := s.repo.ListAPIKeys(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeKey revokes the owner's API key.
+func (s *Service) RevokeKey(ctx context.Context, ownerID, id uuid.UUID) error {
+	if err := s.repo.RevokeAPIKey(ctx, ownerID, id); err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+
+	return nil
+}
+
+// hashKey returns the hex-encoded SHA-256 digest of the plaintext key.
+// Keys carry enough entropy, so a fast unsalted hash is sufficient.
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
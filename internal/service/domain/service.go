@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+	domainrepo "github.com/aliskhannn/url-shortener/internal/repository/domain"
+)
+
+// hostCacheTTL defines how long resolved hosts are cached,
+// i.e. how soon verification and deletion of domains affect redirects.
+const hostCacheTTL = time.Minute
+
+// maxCachedHosts bounds the host cache, since request hosts are client-controlled.
+const maxCachedHosts = 10000
+
+var (
+	ErrInvalidHostname    = errors.New("invalid hostname")
+	ErrNotVerified        = errors.New("domain not verified")
+	ErrVerificationFailed = errors.New("domain verification record not found")
+	ErrDomainInUse        = errors.New("domain has links")
+)
+
+// hostnamePattern defines allowed domain hostnames.
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Resolver looks up DNS TXT records. *net.Resolver satisfies it,
+// other implementations can be plugged in to simulate DNS.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// domainRepository defines the interface for domain persistence operations.
+type domainRepository interface {
+	CreateDomain(ctx context.Context, d model.Domain) (model.Domain, error)
+	GetDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error)
+	GetVerifiedDomain(ctx context.Context, hostname string) (model.Domain, error)
+	ListDomains(ctx context.Context, workspaceID uuid.UUID) ([]model.Domain, error)
+	MarkVerified(ctx context.Context, id uuid.UUID) (model.Domain, error)
+	DeleteDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) error
+}
+
+// linkRepository defines the interface for link persistence operations the Service depends on.
+type linkRepository interface {
+	CountDomainLinks(ctx context.Context, workspaceID uuid.UUID, domain string) (int, error)
+}
+
+// hostEntry is a cached result of resolving a request host.
+type hostEntry struct {
+	domain  model.Domain
+	found   bool
+	expires time.Time
+}
+
+// The Service provides methods for registering, verifying and resolving custom domains.
+type Service struct {
+	repo     domainRepository
+	links    linkRepository
+	resolver Resolver
+
+	mu    sync.RWMutex
+	hosts map[string]hostEntry
+}
+
+// NewService creates a new Service instance with repositories and DNS resolver.
+func NewService(repo domainRepository, links linkRepository, resolver Resolver) *Service {
+	return &Service{repo: repo, links: links, resolver: resolver, hosts: make(map[string]hostEntry)}
+}
+
+// NormalizeHost converts a host or host:port value into a lowercase hostname without port.
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// RegisterDomain registers the hostname for the workspace. The domain must be verified
+// with VerifyDomain before links can use it.
+func (s *Service) RegisterDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error) {
+	hostname = NormalizeHost(hostname)
+	if !hostnamePattern.MatchString(hostname) {
+		return model.Domain{}, ErrInvalidHostname
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return model.Domain{}, fmt.Errorf("generate verification token: %w", err)
+	}
+
+	d, err := s.repo.CreateDomain(ctx, model.Domain{
+		WorkspaceID:       workspaceID,
+		Hostname:          hostname,
+		VerificationToken: hex.EncodeToString(b),
+	})
+	if err != nil {
+		return model.Domain{}, fmt.Errorf("create domain: %w", err)
+	}
+
+	return d, nil
+}
+
+// ListDomains returns all domains of the workspace.
+func (s *Service) ListDomains(ctx context.Context, workspaceID uuid.UUID) ([]model.Domain, error) {
+	domains, err := s.repo.ListDomains(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// VerifyDomain checks the verification TXT record of the workspace domain and marks it as verified.
+func (s *Service) VerifyDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error) {
+	d, err := s.repo.GetDomain(ctx, workspaceID, NormalizeHost(hostname))
+	if err != nil {
+		return model.Domain{}, fmt.Errorf("get domain: %w", err)
+	}
+
+	if d.VerifiedAt != nil {
+		return d, nil // already verified
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, d.VerificationRecord())
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return model.Domain{}, ErrVerificationFailed
+		}
+
+		return model.Domain{}, fmt.Errorf("lookup verification record: %w", err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == d.VerificationValue() {
+			d, err = s.repo.MarkVerified(ctx, d.ID)
+			if err != nil {
+				return model.Domain{}, fmt.Errorf("mark domain verified: %w", err)
+			}
+
+			s.forgetHost(d.Hostname)
+			return d, nil
+		}
+	}
+
+	return model.Domain{}, ErrVerificationFailed
+}
+
+// DeleteDomain deletes the workspace domain. Domains still serving links cannot be deleted.
+func (s *Service) DeleteDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) error {
+	hostname = NormalizeHost(hostname)
+
+	count, err := s.links.CountDomainLinks(ctx, workspaceID, hostname)
+	if err != nil {
+		return fmt.Errorf("count domain links: %w", err)
+	}
+
+	if count > 0 {
+		return ErrDomainInUse
+	}
+
+	if err := s.repo.DeleteDomain(ctx, workspaceID, hostname); err != nil {
+		return fmt.Errorf("delete domain: %w", err)
+	}
+
+	s.forgetHost(hostname)
+	return nil
+}
+
+// GetVerifiedDomain returns the workspace domain if it has been verified, otherwise ErrNotVerified.
+func (s *Service) GetVerifiedDomain(ctx context.Context, workspaceID uuid.UUID, hostname string) (model.Domain, error) {
+	d, err := s.repo.GetDomain(ctx, workspaceID, NormalizeHost(hostname))
+	if err != nil {
+		return model.Domain{}, fmt.Errorf("get domain: %w", err)
+	}
+
+	if d.VerifiedAt == nil {
+		return model.Domain{}, ErrNotVerified
+	}
+
+	return d, nil
+}
+
+// ResolveHost returns the verified domain serving the request host.
+// The boolean result is false if the host is not a registered custom domain.
+func (s *Service) ResolveHost(ctx context.Context, host string) (model.Domain, bool, error) {
+	host = NormalizeHost(host)
+
+	s.mu.RLock()
+	e, ok := s.hosts[host]
+	s.mu.RUnlock()
+
+	if ok && time.Now().Before(e.expires) {
+		return e.domain, e.found, nil
+	}
+
+	d, err := s.repo.GetVerifiedDomain(ctx, host)
+	if err != nil && !errors.Is(err, domainrepo.ErrDomainNotFound) {
+		return model.Domain{}, false, fmt.Errorf("get verified domain: %w", err)
+	}
+
+	e = hostEntry{domain: d, found: err == nil, expires: time.Now().Add(hostCacheTTL)}
+
+	s.mu.Lock()
+	if len(s.hosts) >= maxCachedHosts {
+		s.hosts = make(map[string]hostEntry)
+	}
+	s.hosts[host] = e
+	s.mu.Unlock()
+
+	return e.domain, e.found, nil
+}
+
+// forgetHost drops the cached resolution of the host.
+func (s *Service) forgetHost(host string) {
+	s.mu.Lock()
+	delete(s.hosts, host)
+	s.mu.Unlock()
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// fakeResolver answers TXT lookups from a map of records, or with err.
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+	lookups []string
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	r.lookups = append(r.lookups, name)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+// fakeDomains stores a single domain and fails every call it does not implement.
+type fakeDomains struct {
+	domainRepository
+	domain   model.Domain
+	verified bool
+}
+
+func (r *fakeDomains) GetDomain(context.Context, uuid.UUID, string) (model.Domain, error) {
+	return r.domain, nil
+}
+
+func (r *fakeDomains) MarkVerified(_ context.Context, id uuid.UUID) (model.Domain, error) {
+	if id != r.domain.ID {
+		return model.Domain{}, errors.New("unknown domain")
+	}
+
+	r.verified = true
+	now := time.Now()
+	d := r.domain
+	d.VerifiedAt = &now
+	return d, nil
+}
+
+func TestVerifyDomain(t *testing.T) {
+	d := model.Domain{ID: uuid.New(), Hostname: "go.acme.com", VerificationToken: "token"}
+	lookupErr := &net.DNSError{Err: "server misbehaving", Name: d.VerificationRecord(), IsTemporary: true}
+
+	tests := []struct {
+		name         string
+		domain       model.Domain
+		resolver     *fakeResolver
+		wantErr      error
+		wantAnyErr   bool
+		wantVerified bool
+		wantLookups  int
+	}{
+		{
+			name:         "matching record",
+			domain:       d,
+			resolver:     &fakeResolver{records: map[string][]string{d.VerificationRecord(): {d.VerificationValue()}}},
+			wantVerified: true,
+			wantLookups:  1,
+		},
+		{
+			name:   "matching record among others",
+			domain: d,
+			resolver: &fakeResolver{records: map[string][]string{
+				d.VerificationRecord(): {"v=spf1 -all", " " + d.VerificationValue() + " "},
+			}},
+			wantVerified: true,
+			wantLookups:  1,
+		},
+		{
+			name:        "mismatching record",
+			domain:      d,
+			resolver:    &fakeResolver{records: map[string][]string{d.VerificationRecord(): {"url-shortener-verification=other"}}},
+			wantErr:     ErrVerificationFailed,
+			wantLookups: 1,
+		},
+		{
+			name:        "record on another name",
+			domain:      d,
+			resolver:    &fakeResolver{records: map[string][]string{d.Hostname: {d.VerificationValue()}}},
+			wantErr:     ErrVerificationFailed,
+			wantLookups: 1,
+		},
+		{
+			name:        "lookup error",
+			domain:      d,
+			resolver:    &fakeResolver{err: lookupErr},
+			wantAnyErr:  true,
+			wantLookups: 1,
+		},
+		{
+			name:     "already verified",
+			domain:   func() model.Domain { v := d; now := time.Now(); v.VerifiedAt = &now; return v }(),
+			resolver: &fakeResolver{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeDomains{domain: tt.domain}
+			s := NewService(repo, nil, tt.resolver)
+
+			got, err := s.VerifyDomain(context.Background(), uuid.New(), "GO.acme.com")
+			switch {
+			case tt.wantAnyErr:
+				if err == nil || errors.Is(err, ErrVerificationFailed) {
+					t.Fatalf("VerifyDomain() error = %v, want a lookup error", err)
+				}
+				if !errors.Is(err, lookupErr) {
+					t.Errorf("VerifyDomain() error = %v, want it to wrap %v", err, lookupErr)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("VerifyDomain() error = %v, want %v", err, tt.wantErr)
+			case err == nil && got.VerifiedAt == nil:
+				t.Errorf("VerifyDomain() = %+v, want a verified domain", got)
+			}
+
+			if repo.verified != tt.wantVerified {
+				t.Errorf("domain marked verified = %t, want %t", repo.verified, tt.wantVerified)
+			}
+			if len(tt.resolver.lookups) != tt.wantLookups {
+				t.Errorf("TXT lookups = %v, want %d", tt.resolver.lookups, tt.wantLookups)
+			}
+			for _, name := range tt.resolver.lookups {
+				if name != d.VerificationRecord() {
+					t.Errorf("looked up %q, want %q", name, d.VerificationRecord())
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"go.acme.com", "go.acme.com"},
+		{"GO.Acme.COM", "go.acme.com"},
+		{"go.acme.com:8080", "go.acme.com"},
+		{"go.acme.com.", "go.acme.com"},
+		{"[::1]:80", "::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := NormalizeHost(tt.host); got != tt.want {
+				t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
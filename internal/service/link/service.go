@@ -2,12 +2,16 @@ package link
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
 
@@ -15,47 +19,88 @@ import (
 	linkrepo "github.com/aliskhannn/url-shortener/internal/repository/link"
 )
 
+// Sweep modes supported by SweepExpiredLinks.
+const (
+	SweepModeArchive = "archive"
+	SweepModeDelete  = "delete"
+)
+
 var (
 	ErrAliasAlreadyExists = errors.New("alias already exists")
+	ErrAliasReserved      = errors.New("alias reserved")
+	ErrLinkExpired        = errors.New("link expired")
+	ErrLinkDisabled       = errors.New("link disabled")
+	ErrUnknownSweepMode   = errors.New("unknown sweep mode")
+	ErrInvalidCursor      = errors.New("invalid cursor")
 )
 
 // linkRepository defines the interface for link persistence operations.
 type linkRepository interface {
 	CreateLink(ctx context.Context, link model.Link) (model.Link, error)
-	GetLinkByAlias(ctx context.Context, alias string) (model.Link, error)
+	GetLinkByAlias(ctx context.Context, key model.LinkKey) (model.Link, error)
+	UpdateLink(ctx context.Context, key model.LinkKey, upd model.LinkUpdate) (model.Link, error)
+	DeleteLink(ctx context.Context, key model.LinkKey) error
+	ClaimLinks(ctx context.Context, workspaceID, ownerID uuid.UUID, aliases []string) ([]model.LinkKey, error)
+	ListLinks(ctx context.Context, filter model.LinkFilter, after *model.LinkCursor) ([]model.LinkListItem, error)
+	DeleteExpiredLinks(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	ArchiveExpiredLinks(ctx context.Context, cutoff time.Time, limit int) (int64, error)
 }
 
 // cache defines the interface for caching links.
 type cache interface {
 	SetWithRetry(ctx context.Context, strategy retry.Strategy, key string, value interface{}) error
+	SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	GetWithRetry(ctx context.Context, strategy retry.Strategy, key string) (string, error)
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
 }
 
 // The Service provides methods for creating and retrieving links.
 type Service struct {
-	repo  linkRepository
-	cache cache
+	repo     linkRepository
+	cache    cache
+	reserved map[string]struct{} // lowercased aliases that collide with application paths
 }
 
 // NewService creates a new Service instance with repository and cache.
-func NewService(repo linkRepository, cache cache) *Service {
-	return &Service{repo: repo, cache: cache}
+// Reserved aliases, compared case-insensitively, cannot be taken by links.
+func NewService(repo linkRepository, cache cache, reserved []string) *Service {
+	s := &Service{repo: repo, cache: cache, reserved: make(map[string]struct{}, len(reserved))}
+	for _, alias := range reserved {
+		s.reserved[strings.ToLower(alias)] = struct{}{}
+	}
+
+	return s
 }
 
-// CreateLink creates a new link and caches it.
+// IsReserved reports whether the alias collides with an application path.
+func (s *Service) IsReserved(alias string) bool {
+	_, ok := s.reserved[strings.ToLower(alias)]
+	return ok
+}
+
+// CreateLink creates a new link in the link's workspace and domain and caches it.
 func (s *Service) CreateLink(ctx context.Context, strategy retry.Strategy, link model.Link) (model.Link, error) {
 	// If no alias provided, generate random 6-character alias.
 	if link.Alias == "" {
 		for {
 			link.Alias = s.generateRandomAlias(6) // 6-character alias
-			_, err := s.GetLinkByAlias(ctx, strategy, link.Alias)
+			if s.IsReserved(link.Alias) {
+				continue
+			}
+
+			_, err := s.repo.GetLinkByAlias(ctx, link.Key())
 			if errors.Is(err, linkrepo.ErrAliasNotFound) {
 				break // unique alias found
 			}
 		}
 	} else {
+		if s.IsReserved(link.Alias) {
+			return model.Link{}, ErrAliasReserved
+		}
+
 		// If alias provided check if it already exists.
-		_, err := s.GetLinkByAlias(ctx, strategy, link.Alias)
+		// Expired links still hold their alias until the sweeper deletes them.
+		_, err := s.repo.GetLinkByAlias(ctx, link.Key())
 		if err != nil && !errors.Is(err, linkrepo.ErrAliasNotFound) {
 			return model.Link{}, fmt.Errorf("failed to check existing alias: %w", err)
 		}
@@ -70,18 +115,11 @@ func (s *Service) CreateLink(ctx context.Context, strategy retry.Strategy, link
 		return model.Link{}, fmt.Errorf("create link: %w", err)
 	}
 
-	// Marshal link into JSON before caching.
-	b, err := json.Marshal(link)
-	if err != nil {
-		return model.Link{}, fmt.Errorf("marshal link: %w", err)
-	}
-
 	// Cache the link.
-	err = s.cache.SetWithRetry(ctx, strategy, link.Alias, string(b))
-	if err != nil {
+	if err := s.cacheLink(ctx, strategy, res); err != nil {
 		zlog.Logger.Error().
 			Err(err).
-			Str("alias", link.Alias).
+			Str("alias", res.Alias).
 			Msg("failed to cache link")
 	}
 
@@ -98,40 +136,30 @@ func (s *Service) generateRandomAlias(length int) string {
 	return string(b)
 }
 
-// GetLinkByAlias retrieves a shortened link by its alias.
+// GetLinkByAlias retrieves a shortened link by its key.
 // It first tries to get the link from cache. If the cache misses,
 // it fetches the link from the repository and updates the cache.
-func (s *Service) GetLinkByAlias(ctx context.Context, strategy retry.Strategy, alias string) (model.Link, error) {
+// Expired links are reported with ErrLinkExpired.
+func (s *Service) GetLinkByAlias(ctx context.Context, strategy retry.Strategy, key model.LinkKey) (model.Link, error) {
 	var link model.Link
 
 	// Check cache first.
-	str, err := s.cache.GetWithRetry(ctx, strategy, alias)
+	str, err := s.cache.GetWithRetry(ctx, strategy, cacheKey(key))
 	if err == nil {
 		// Unmarshal cached JSON into a link.
 		err = json.Unmarshal([]byte(str), &link)
 		if err != nil {
 			return model.Link{}, fmt.Errorf("unmarshal link: %w", err)
 		}
-
-		return link, nil // cache hit
-	}
-
-	// If cache misses, fetch from repo and update cache.
-	if errors.Is(err, redis.Nil) {
-		link, err = s.repo.GetLinkByAlias(ctx, alias)
+	} else {
+		// If cache misses, fetch from repo and update cache.
+		link, err = s.repo.GetLinkByAlias(ctx, key)
 		if err != nil {
 			return model.Link{}, fmt.Errorf("get link by alias: %w", err)
 		}
 
-		// Marshal link into JSON before caching.
-		b, err := json.Marshal(link)
-		if err != nil {
-			return model.Link{}, fmt.Errorf("marshal link: %w", err)
-		}
-
 		// Cache the link.
-		err = s.cache.SetWithRetry(ctx, strategy, link.Alias, string(b))
-		if err != nil {
+		if err := s.cacheLink(ctx, strategy, link); err != nil {
 			zlog.Logger.Error().
 				Err(err).
 				Str("alias", link.Alias).
@@ -139,5 +167,198 @@ func (s *Service) GetLinkByAlias(ctx context.Context, strategy retry.Strategy, a
 		}
 	}
 
+	if link.IsExpired(time.Now()) {
+		return model.Link{}, ErrLinkExpired
+	}
+
+	if !link.Enabled {
+		return model.Link{}, ErrLinkDisabled
+	}
+
+	return link, nil
+}
+
+// GetLink retrieves a link by its key for management purposes.
+// Unlike GetLinkByAlias, it bypasses cache and returns expired and disabled links as well.
+func (s *Service) GetLink(ctx context.Context, key model.LinkKey) (model.Link, error) {
+	link, err := s.repo.GetLinkByAlias(ctx, key)
+	if err != nil {
+		return model.Link{}, fmt.Errorf("get link by alias: %w", err)
+	}
+
+	return link, nil
+}
+
+// UpdateLink applies a partial update to a link and invalidates its cache entry.
+func (s *Service) UpdateLink(
+	ctx context.Context,
+	strategy retry.Strategy,
+	key model.LinkKey,
+	upd model.LinkUpdate,
+) (model.Link, error) {
+	link, err := s.repo.UpdateLink(ctx, key, upd)
+	if err != nil {
+		return model.Link{}, fmt.Errorf("update link: %w", err)
+	}
+
+	if err := s.invalidateLink(ctx, strategy, key); err != nil {
+		return model.Link{}, err
+	}
+
 	return link, nil
 }
+
+// DeleteLink deletes a link and invalidates its cache entry.
+func (s *Service) DeleteLink(ctx context.Context, strategy retry.Strategy, key model.LinkKey) error {
+	if err := s.repo.DeleteLink(ctx, key); err != nil {
+		return fmt.Errorf("delete link: %w", err)
+	}
+
+	return s.invalidateLink(ctx, strategy, key)
+}
+
+// ClaimLinks makes the owner the owner of links of the workspace without one, limited to
+// the aliases unless none are given, and returns the number of claimed links.
+func (s *Service) ClaimLinks(
+	ctx context.Context, strategy retry.Strategy, workspaceID, ownerID uuid.UUID, aliases []string,
+) (int, error) {
+	keys, err := s.repo.ClaimLinks(ctx, workspaceID, ownerID, aliases)
+	if err != nil {
+		return 0, fmt.Errorf("claim links: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := s.invalidateLink(ctx, strategy, key); err != nil {
+			zlog.Logger.Error().Err(err).Str("alias", key.Alias).Msg("failed to invalidate claimed link")
+		}
+	}
+
+	return len(keys), nil
+}
+
+// ListLinks returns a page of links matching the filter.
+// The cursor is an opaque string returned as NextCursor of the previous page, empty for the first page.
+func (s *Service) ListLinks(ctx context.Context, filter model.LinkFilter, cursor string) (model.LinkPage, error) {
+	var after *model.LinkCursor
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil || c.Sort != filter.Sort {
+			return model.LinkPage{}, ErrInvalidCursor
+		}
+		after = &c
+	}
+
+	// Fetch one extra link to find out whether there is a next page.
+	pageSize := filter.Limit
+	filter.Limit++
+
+	items, err := s.repo.ListLinks(ctx, filter, after)
+	if err != nil {
+		return model.LinkPage{}, fmt.Errorf("list links: %w", err)
+	}
+
+	page := model.LinkPage{Items: items}
+	if len(items) > pageSize {
+		page.Items = items[:pageSize]
+
+		last := page.Items[pageSize-1]
+		page.NextCursor = encodeCursor(model.LinkCursor{
+			Sort:      filter.Sort,
+			CreatedAt: last.CreatedAt,
+			Clicks:    last.Clicks,
+			ID:        last.ID,
+		})
+	}
+
+	return page, nil
+}
+
+// SweepExpiredLinks archives or deletes at most batchSize links that expired before the cutoff,
+// depending on mode, and returns the number of affected links.
+func (s *Service) SweepExpiredLinks(ctx context.Context, mode string, cutoff time.Time, batchSize int) (int64, error) {
+	switch mode {
+	case SweepModeArchive:
+		n, err := s.repo.ArchiveExpiredLinks(ctx, cutoff, batchSize)
+		if err != nil {
+			return 0, fmt.Errorf("archive expired links: %w", err)
+		}
+		return n, nil
+	case SweepModeDelete:
+		n, err := s.repo.DeleteExpiredLinks(ctx, cutoff, batchSize)
+		if err != nil {
+			return 0, fmt.Errorf("delete expired links: %w", err)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownSweepMode, mode)
+	}
+}
+
+// encodeCursor serializes a listing cursor into an opaque URL-safe string.
+func encodeCursor(c model.LinkCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a listing cursor produced by encodeCursor.
+func decodeCursor(s string) (model.LinkCursor, error) {
+	var c model.LinkCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("unmarshal cursor: %w", err)
+	}
+
+	return c, nil
+}
+
+// invalidateLink removes the cached link, so that redirects never serve a stale target.
+func (s *Service) invalidateLink(ctx context.Context, strategy retry.Strategy, key model.LinkKey) error {
+	err := retry.Do(func() error {
+		return s.cache.Del(ctx, cacheKey(key)).Err()
+	}, strategy)
+	if err != nil {
+		return fmt.Errorf("invalidate cached link: %w", err)
+	}
+
+	return nil
+}
+
+// cacheLink stores the link in cache. Links with an expiration timestamp
+// are cached only until they expire, so the cache never outlives the link.
+func (s *Service) cacheLink(ctx context.Context, strategy retry.Strategy, link model.Link) error {
+	// Marshal link into JSON before caching.
+	b, err := json.Marshal(link)
+	if err != nil {
+		return fmt.Errorf("marshal link: %w", err)
+	}
+
+	key := cacheKey(link.Key())
+
+	if link.ExpiresAt == nil {
+		return s.cache.SetWithRetry(ctx, strategy, key, string(b))
+	}
+
+	ttl := time.Until(*link.ExpiresAt)
+	if ttl <= 0 {
+		return nil // already expired, nothing to cache
+	}
+
+	return retry.Do(func() error {
+		return s.cache.SetWithExpiration(ctx, key, string(b), ttl)
+	}, strategy)
+}
+
+// cacheKeyPrefix prefixes cache keys of links. Its version is bumped whenever cached links
+// would decode differently, e.g. entries without the enabled flag as disabled links, since
+// links are cached without a TTL unless they expire.
+const cacheKeyPrefix = "link:v2:"
+
+// cacheKey returns the cache key of a link.
+func cacheKey(key model.LinkKey) string {
+	return cacheKeyPrefix + key.WorkspaceID.String() + ":" + key.Domain + ":" + key.Alias
+}
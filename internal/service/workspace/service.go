@@ -0,0 +1,207 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/url-shortener/internal/model"
+	workspacerepo "github.com/aliskhannn/url-shortener/internal/repository/workspace"
+)
+
+var (
+	ErrInvalidSlug = errors.New("invalid workspace slug")
+	ErrInvalidRole = errors.New("invalid workspace role")
+	ErrNotMember   = errors.New("not a workspace member")
+	ErrLastOwner   = errors.New("workspace must keep at least one owner")
+)
+
+// slugPattern defines allowed workspace slugs.
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+// workspaceRepository defines the interface for workspace persistence operations.
+type workspaceRepository interface {
+	CreateWorkspace(ctx context.Context, ws model.Workspace, ownerID uuid.UUID) (model.Workspace, error)
+	GetWorkspaceBySlug(ctx context.Context, slug string) (model.Workspace, error)
+	ListUserWorkspaces(ctx context.Context, userID uuid.UUID) ([]model.UserWorkspace, error)
+	GetMember(ctx context.Context, workspaceID, userID uuid.UUID) (model.WorkspaceMember, error)
+	ListMembers(ctx context.Context, workspaceID uuid.UUID) ([]model.WorkspaceMember, error)
+	UpsertMember(ctx context.Context, m model.WorkspaceMember) (model.WorkspaceMember, error)
+	DeleteMember(ctx context.Context, workspaceID, userID uuid.UUID) error
+	CountOwners(ctx context.Context, workspaceID uuid.UUID) (int, error)
+}
+
+// The Service provides methods for managing workspaces and their members.
+type Service struct {
+	repo workspaceRepository
+
+	// bySlug caches workspaces by slug. Workspaces are never renamed or deleted,
+	// so entries never go stale.
+	bySlug sync.Map
+}
+
+// NewService creates a new Service instance with repository.
+func NewService(repo workspaceRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// CreateWorkspace creates a new workspace owned by the user.
+func (s *Service) CreateWorkspace(ctx context.Context, userID uuid.UUID, slug, name string) (model.Workspace, error) {
+	if !slugPattern.MatchString(slug) {
+		return model.Workspace{}, ErrInvalidSlug
+	}
+
+	ws, err := s.repo.CreateWorkspace(ctx, model.Workspace{Slug: slug, Name: name}, userID)
+	if err != nil {
+		return model.Workspace{}, fmt.Errorf("create workspace: %w", err)
+	}
+
+	return ws, nil
+}
+
+// ListWorkspaces returns all workspaces the user is a member of.
+func (s *Service) ListWorkspaces(ctx context.Context, userID uuid.UUID) ([]model.UserWorkspace, error) {
+	workspaces, err := s.repo.ListUserWorkspaces(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list user workspaces: %w", err)
+	}
+
+	return workspaces, nil
+}
+
+// GetWorkspaceBySlug retrieves a workspace by its slug.
+func (s *Service) GetWorkspaceBySlug(ctx context.Context, slug string) (model.Workspace, error) {
+	if ws, ok := s.bySlug.Load(slug); ok {
+		return ws.(model.Workspace), nil
+	}
+
+	ws, err := s.repo.GetWorkspaceBySlug(ctx, slug)
+	if err != nil {
+		return model.Workspace{}, fmt.Errorf("get workspace by slug: %w", err)
+	}
+
+	s.bySlug.Store(slug, ws)
+
+	return ws, nil
+}
+
+// Authorize resolves the workspace by slug and returns it together with the user's role in it.
+// Users that are not members of the workspace are reported with ErrNotMember.
+func (s *Service) Authorize(ctx context.Context, slug string, userID uuid.UUID) (model.Workspace, string, error) {
+	ws, err := s.GetWorkspaceBySlug(ctx, slug)
+	if err != nil {
+		return model.Workspace{}, "", err
+	}
+
+	m, err := s.repo.GetMember(ctx, ws.ID, userID)
+	if err != nil {
+		if errors.Is(err, workspacerepo.ErrMemberNotFound) {
+			return model.Workspace{}, "", ErrNotMember
+		}
+
+		return model.Workspace{}, "", fmt.Errorf("get workspace member: %w", err)
+	}
+
+	return ws, m.Role, nil
+}
+
+// EnsureMember adds the user to the workspace with the role, keeping the role of users
+// that are already members.
+func (s *Service) EnsureMember(ctx context.Context, workspaceID, userID uuid.UUID, role string) error {
+	if !model.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	_, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, workspacerepo.ErrMemberNotFound) {
+		return fmt.Errorf("get workspace member: %w", err)
+	}
+
+	if _, err := s.repo.UpsertMember(ctx, model.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: role}); err != nil {
+		return fmt.Errorf("add workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// ListMembers returns all members of the workspace.
+func (s *Service) ListMembers(ctx context.Context, workspaceID uuid.UUID) ([]model.WorkspaceMember, error) {
+	members, err := s.repo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list workspace members: %w", err)
+	}
+
+	return members, nil
+}
+
+// SetMemberRole adds the user to the workspace with the role or changes the role of an existing member.
+// The last owner of a workspace cannot be demoted.
+func (s *Service) SetMemberRole(
+	ctx context.Context,
+	workspaceID, userID uuid.UUID,
+	role string,
+) (model.WorkspaceMember, error) {
+	if !model.IsValidRole(role) {
+		return model.WorkspaceMember{}, ErrInvalidRole
+	}
+
+	if role != model.RoleOwner {
+		if err := s.ensureNotLastOwner(ctx, workspaceID, userID); err != nil {
+			return model.WorkspaceMember{}, err
+		}
+	}
+
+	m, err := s.repo.UpsertMember(ctx, model.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: role})
+	if err != nil {
+		return model.WorkspaceMember{}, fmt.Errorf("set workspace member role: %w", err)
+	}
+
+	return m, nil
+}
+
+// RemoveMember removes the user from the workspace. The last owner of a workspace cannot be removed.
+func (s *Service) RemoveMember(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	if err := s.ensureNotLastOwner(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteMember(ctx, workspaceID, userID); err != nil {
+		return fmt.Errorf("remove workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// ensureNotLastOwner returns ErrLastOwner if the user is the only owner of the workspace.
+func (s *Service) ensureNotLastOwner(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	m, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, workspacerepo.ErrMemberNotFound) {
+			return nil // not a member yet
+		}
+
+		return fmt.Errorf("get workspace member: %w", err)
+	}
+
+	if m.Role != model.RoleOwner {
+		return nil
+	}
+
+	owners, err := s.repo.CountOwners(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("count workspace owners: %w", err)
+	}
+
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package visitor identifies unique visitors of links without storing who they are
+// and counts them with Redis HyperLogLogs.
+package visitor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/redis"
+)
+
+// saltTTL keeps the salt of a day available while its last clicks are processed.
+const saltTTL = 48 * time.Hour
+
+// Store hashes visitor fingerprints with a salt rotating daily and maintains
+// per-link HyperLogLogs of visitor IDs.
+type Store struct {
+	rdb *redis.Client
+
+	mu   sync.Mutex // guards day and salt
+	day  string     // UTC date of the cached salt
+	salt string     // salt of day
+}
+
+// New creates a new Store instance.
+func New(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// ID returns the visitor ID of the client IP and user agent at the given moment: a hash
+// of both with the salt of the UTC day. The salt is random, shared through Redis by all
+// instances and expires shortly after its day, so IDs cannot be traced back to visitors
+// and the same visitor gets a new ID every day.
+func (s *Store) ID(ctx context.Context, ip, userAgent string, at time.Time) (string, error) {
+	salt, err := s.saltOf(ctx, at.UTC().Format(time.DateOnly))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(salt + "|" + ip + "|" + userAgent))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// HashIP returns a hash of the client IP with the salt of the UTC day of the given moment,
+// which identifies the IP only while the salt of that day is kept.
+func (s *Store) HashIP(ctx context.Context, ip string, at time.Time) (string, error) {
+	salt, err := s.saltOf(ctx, at.UTC().Format(time.DateOnly))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(salt + "|ip|" + ip))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// saltOf returns the salt of the day, creating it if this instance is the first to need it.
+// Redis is called without holding the lock, so that a slow Redis delays only callers
+// missing the cached salt; concurrent callers of a new day all get the same salt from it.
+func (s *Store) saltOf(ctx context.Context, day string) (string, error) {
+	s.mu.Lock()
+	cachedDay, cached := s.day, s.salt
+	s.mu.Unlock()
+
+	if cachedDay == day {
+		return cached, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+
+	key := "visitor:salt:" + day
+	if err := s.rdb.SetNX(ctx, key, hex.EncodeToString(b), saltTTL).Err(); err != nil {
+		return "", fmt.Errorf("set salt: %w", err)
+	}
+
+	salt, err := s.rdb.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("get salt: %w", err)
+	}
+
+	// Only the latest day is cached, salts of late events of past days are read each time.
+	s.mu.Lock()
+	if day > s.day {
+		s.day, s.salt = day, salt
+	}
+	s.mu.Unlock()
+
+	return salt, nil
+}
+
+// Add adds visitor IDs to the HyperLogLog of the link. Creating the HyperLogLog, e.g.
+// after it was evicted, clears the mark of its completeness, see MarkComplete.
+func (s *Store) Add(ctx context.Context, linkID uuid.UUID, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, id)
+	}
+
+	var exists *goredis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		exists = pipe.Exists(ctx, hllKey(linkID))
+		pipe.PFAdd(ctx, hllKey(linkID), members...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add visitors: %w", err)
+	}
+
+	if exists.Val() == 0 {
+		if err := s.rdb.Del(ctx, completeKey(linkID)).Err(); err != nil {
+			return fmt.Errorf("clear visitors mark: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MarkComplete marks the HyperLogLog of the link as holding all its visitors,
+// once they were restored from the database.
+func (s *Store) MarkComplete(ctx context.Context, linkID uuid.UUID) error {
+	if err := s.rdb.Set(ctx, completeKey(linkID), 1); err != nil {
+		return fmt.Errorf("mark visitors complete: %w", err)
+	}
+
+	return nil
+}
+
+// Count returns the estimated number of unique visitors of the link and whether the
+// HyperLogLog holds all of them, i.e. it was marked complete and not lost since.
+func (s *Store) Count(ctx context.Context, linkID uuid.UUID) (int, bool, error) {
+	var (
+		count  *goredis.IntCmd
+		marked *goredis.IntCmd
+	)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		count = pipe.PFCount(ctx, hllKey(linkID))
+		marked = pipe.Exists(ctx, hllKey(linkID), completeKey(linkID))
+		return nil
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("count visitors: %w", err)
+	}
+
+	return int(count.Val()), marked.Val() == 2, nil
+}
+
+// hllKey returns the key of the HyperLogLog of the link visitors.
+func hllKey(linkID uuid.UUID) string {
+	return "visitors:" + linkID.String()
+}
+
+// completeKey returns the key marking the HyperLogLog of the link visitors complete.
+func completeKey(linkID uuid.UUID) string {
+	return "visitors:complete:" + linkID.String()
+}
@@ -0,0 +1,248 @@
+package clickstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// analyticsService defines the interface that the Consumer depends on.
+type analyticsService interface {
+	SaveAnalyticsBatch(ctx context.Context, strategy retry.Strategy, events []model.Analytics) error
+}
+
+// Consumer reads click events of the stream as a member of the consumer group and saves
+// them. Entries are acknowledged once saved; entries left pending longer than the retry
+// interval are claimed again, and moved to the dead-letter stream after the configured
+// number of deliveries.
+type Consumer struct {
+	rdb              *redis.Client
+	analyticsService analyticsService
+	cfg              config.Stream
+	strategy         retry.Strategy
+	name             string // consumer name within the group
+}
+
+// NewConsumer creates a new Consumer instance named after the host and process.
+func NewConsumer(rdb *redis.Client, as analyticsService, cfg config.Stream, strategy retry.Strategy) *Consumer {
+	host, _ := os.Hostname()
+
+	return &Consumer{
+		rdb:              rdb,
+		analyticsService: as,
+		cfg:              withDefaults(cfg),
+		strategy:         strategy,
+		name:             fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// Run consumes the stream until ctx is cancelled. The batch being saved when ctx is
+// cancelled is completed, unacknowledged entries are picked up by the next run.
+func (c *Consumer) Run(ctx context.Context) error {
+	err := c.rdb.XGroupCreateMkStream(ctx, c.cfg.Key, c.cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+
+	zlog.Logger.Info().Str("stream", c.cfg.Key).Str("consumer", c.name).Msg("consuming click events")
+
+	nextRetry := time.Now()
+	for ctx.Err() == nil {
+		if time.Now().After(nextRetry) {
+			c.retryPending(ctx)
+			nextRetry = time.Now().Add(c.cfg.RetryInterval)
+		}
+
+		streams, err := c.rdb.XReadGroup(ctx, &goredis.XReadGroupArgs{
+			Group:    c.cfg.Group,
+			Consumer: c.name,
+			Streams:  []string{c.cfg.Key, ">"},
+			Count:    int64(c.cfg.BatchSize),
+			Block:    c.cfg.Block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, goredis.Nil) || ctx.Err() != nil {
+				continue
+			}
+
+			zlog.Logger.Error().Err(err).Str("stream", c.cfg.Key).Msg("failed to read click events")
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		for _, s := range streams {
+			c.process(s.Messages)
+		}
+	}
+
+	return nil
+}
+
+// process saves events of the entries and acknowledges the saved ones. If the batch
+// fails, events are saved one by one so that a bad event does not hold back the others.
+func (c *Consumer) process(msgs []goredis.XMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	// Finish the batch even if the worker is shutting down.
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.WriteTimeout)
+	defer cancel()
+
+	events := make([]model.Analytics, 0, len(msgs))
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		event, err := decode(msg)
+		if err != nil {
+			c.deadLetter(ctx, msg, err)
+			continue
+		}
+
+		events = append(events, event)
+		ids = append(ids, msg.ID)
+	}
+
+	if len(events) == 0 {
+		return
+	}
+
+	if err := c.analyticsService.SaveAnalyticsBatch(ctx, c.strategy, events); err == nil {
+		c.ack(ctx, ids...)
+		return
+	}
+
+	for i, event := range events {
+		if err := c.analyticsService.SaveAnalyticsBatch(ctx, c.strategy, []model.Analytics{event}); err != nil {
+			zlog.Logger.Error().Err(err).Str("id", ids[i]).Msg("failed to save click event, will retry")
+			continue
+		}
+
+		c.ack(ctx, ids[i])
+	}
+}
+
+// retryPending claims entries pending longer than the retry interval to process them
+// again, and moves those delivered too many times to the dead-letter stream.
+func (c *Consumer) retryPending(ctx context.Context) {
+	pending, err := c.rdb.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream: c.cfg.Key,
+		Group:  c.cfg.Group,
+		Idle:   c.cfg.RetryInterval,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(c.cfg.BatchSize),
+	}).Result()
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("stream", c.cfg.Key).Msg("failed to list pending click events")
+		return
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	exhausted := make(map[string]bool)
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		ids = append(ids, p.ID)
+		if p.RetryCount >= int64(c.cfg.MaxDeliveries) {
+			exhausted[p.ID] = true
+		}
+	}
+
+	msgs, err := c.rdb.XClaim(ctx, &goredis.XClaimArgs{
+		Stream:   c.cfg.Key,
+		Group:    c.cfg.Group,
+		Consumer: c.name,
+		MinIdle:  c.cfg.RetryInterval,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("stream", c.cfg.Key).Msg("failed to claim pending click events")
+		return
+	}
+
+	retries := make([]goredis.XMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		if exhausted[msg.ID] {
+			c.deadLetter(ctx, msg, fmt.Errorf("delivered %d times", c.cfg.MaxDeliveries))
+			continue
+		}
+
+		retries = append(retries, msg)
+	}
+
+	c.process(retries)
+}
+
+// deadLetter moves the entry to the dead-letter stream with the reason of the failure.
+func (c *Consumer) deadLetter(ctx context.Context, msg goredis.XMessage, reason error) {
+	values := map[string]interface{}{
+		"id":        msg.ID,
+		"error":     reason.Error(),
+		"failed_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if v, ok := msg.Values[eventField]; ok {
+		values[eventField] = v
+	}
+
+	err := c.rdb.XAdd(ctx, &goredis.XAddArgs{
+		Stream: c.cfg.DeadLetterKey,
+		MaxLen: c.cfg.MaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("id", msg.ID).Msg("failed to dead-letter click event")
+		return
+	}
+
+	zlog.Logger.Warn().Err(reason).Str("id", msg.ID).Msg("click event dead-lettered")
+	c.ack(ctx, msg.ID)
+}
+
+// ack acknowledges the entries within the consumer group.
+func (c *Consumer) ack(ctx context.Context, ids ...string) {
+	if err := c.rdb.XAck(ctx, c.cfg.Key, c.cfg.Group, ids...).Err(); err != nil {
+		zlog.Logger.Error().Err(err).Strs("ids", ids).Msg("failed to acknowledge click events")
+	}
+}
+
+// decode returns the event of the stream entry.
+func decode(msg goredis.XMessage) (model.Analytics, error) {
+	var event model.Analytics
+
+	v, ok := msg.Values[eventField].(string)
+	if !ok {
+		return event, fmt.Errorf("missing %s field", eventField)
+	}
+
+	if err := json.Unmarshal([]byte(v), &event); err != nil {
+		return event, fmt.Errorf("decode event: %w", err)
+	}
+
+	return event, nil
+}
+
+// sleep waits for the duration or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
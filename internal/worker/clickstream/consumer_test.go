@@ -0,0 +1,257 @@
+package clickstream
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// fakeService records saved events by alias, failing any batch with the alias bad.
+type fakeService struct {
+	mu    sync.Mutex
+	saved []string
+	calls int
+}
+
+func (s *fakeService) SaveAnalyticsBatch(_ context.Context, _ retry.Strategy, events []model.Analytics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	for _, e := range events {
+		if e.Alias == "bad" {
+			return errors.New("invalid event")
+		}
+	}
+	for _, e := range events {
+		s.saved = append(s.saved, e.Alias)
+	}
+	return nil
+}
+
+func (s *fakeService) aliases() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.saved)
+}
+
+var testStream = config.Stream{
+	Key:           "clicks",
+	DeadLetterKey: "clicks:dead",
+	Group:         "workers",
+	Block:         10 * time.Millisecond,
+	RetryInterval: time.Minute,
+	MaxDeliveries: 3,
+}
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := &redis.Client{Client: goredis.NewClient(&goredis.Options{Addr: mr.Addr()})}
+	t.Cleanup(func() { rdb.Close() })
+
+	return mr, rdb
+}
+
+// read delivers the new entries of the stream to the consumer.
+func read(t *testing.T, c *Consumer) []goredis.XMessage {
+	t.Helper()
+
+	streams, err := c.rdb.XReadGroup(context.Background(), &goredis.XReadGroupArgs{
+		Group:    c.cfg.Group,
+		Consumer: c.name,
+		Streams:  []string{c.cfg.Key, ">"},
+		Count:    int64(c.cfg.BatchSize),
+		Block:    -1,
+	}).Result()
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+
+	return streams[0].Messages
+}
+
+func pending(t *testing.T, rdb *redis.Client) int64 {
+	t.Helper()
+
+	p, err := rdb.XPending(context.Background(), testStream.Key, testStream.Group).Result()
+	if err != nil {
+		t.Fatalf("list pending entries: %v", err)
+	}
+
+	return p.Count
+}
+
+func TestConsumerRun(t *testing.T) {
+	_, rdb := newRedis(t)
+	svc := &fakeService{}
+	fallback := &fakeService{}
+	p := NewProducer(rdb, testStream, fallback)
+	c := NewConsumer(rdb, svc, testStream, retry.Strategy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	events := []model.Analytics{{Alias: "a"}, {Alias: "b"}, {Alias: "c"}}
+	if err := p.SaveAnalyticsBatch(ctx, retry.Strategy{}, events); err != nil {
+		t.Fatalf("SaveAnalyticsBatch() error = %v", err)
+	}
+	if fallback.calls != 0 {
+		t.Fatalf("fallback save calls = %d, want 0", fallback.calls)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(svc.aliases()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("saved = %v, want 3 events", svc.aliases())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := svc.aliases(); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("saved = %v, want [a b c]", got)
+	}
+	if n := pending(t, rdb); n != 0 {
+		t.Errorf("pending entries = %d, want 0", n)
+	}
+}
+
+func TestConsumerRetriesAndDeadLetters(t *testing.T) {
+	mr, rdb := newRedis(t)
+	svc := &fakeService{}
+	p := NewProducer(rdb, testStream, &fakeService{})
+	c := NewConsumer(rdb, svc, testStream, retry.Strategy{})
+	ctx := context.Background()
+
+	if err := rdb.XGroupCreateMkStream(ctx, testStream.Key, testStream.Group, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	mr.SetTime(now)
+
+	if err := p.SaveAnalyticsBatch(ctx, retry.Strategy{}, []model.Analytics{{Alias: "a"}, {Alias: "bad"}}); err != nil {
+		t.Fatal(err)
+	}
+	c.process(read(t, c))
+
+	if got := svc.aliases(); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("saved = %v, want [a]", got)
+	}
+	if n := pending(t, rdb); n != 1 {
+		t.Fatalf("pending entries = %d, want the failed one", n)
+	}
+
+	// Entries idle for less than the retry interval are left alone.
+	calls := svc.calls
+	c.retryPending(ctx)
+	if svc.calls != calls {
+		t.Fatalf("retried an entry before the retry interval")
+	}
+
+	// The failed entry is delivered again until it was delivered MaxDeliveries times.
+	for delivery := 2; delivery <= testStream.MaxDeliveries; delivery++ {
+		now = now.Add(testStream.RetryInterval)
+		mr.SetTime(now)
+		c.retryPending(ctx)
+
+		if svc.calls == calls {
+			t.Fatalf("delivery %d: entry not saved again", delivery)
+		}
+		calls = svc.calls
+
+		if n := pending(t, rdb); n != 1 {
+			t.Fatalf("delivery %d: pending entries = %d, want 1", delivery, n)
+		}
+	}
+
+	now = now.Add(testStream.RetryInterval)
+	mr.SetTime(now)
+	c.retryPending(ctx)
+
+	if svc.calls != calls {
+		t.Errorf("save calls = %d, want no save of an exhausted entry", svc.calls)
+	}
+	if n := pending(t, rdb); n != 0 {
+		t.Errorf("pending entries = %d, want 0", n)
+	}
+
+	dead, err := rdb.XRange(ctx, testStream.DeadLetterKey, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("dead-lettered entries = %d, want 1", len(dead))
+	}
+	if got := dead[0].Values["error"]; got != "delivered 3 times" {
+		t.Errorf("dead-letter error = %v, want %q", got, "delivered 3 times")
+	}
+	if event, err := decode(dead[0]); err != nil || event.Alias != "bad" {
+		t.Errorf("dead-lettered event = %+v, %v, want the bad event", event, err)
+	}
+}
+
+func TestConsumerDeadLettersMalformedEntries(t *testing.T) {
+	_, rdb := newRedis(t)
+	svc := &fakeService{}
+	c := NewConsumer(rdb, svc, testStream, retry.Strategy{})
+	ctx := context.Background()
+
+	if err := rdb.XGroupCreateMkStream(ctx, testStream.Key, testStream.Group, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range []map[string]interface{}{
+		{"other": "x"},
+		{eventField: "{not json"},
+	} {
+		if err := rdb.XAdd(ctx, &goredis.XAddArgs{Stream: testStream.Key, Values: values}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.process(read(t, c))
+
+	if svc.calls != 0 {
+		t.Errorf("save calls = %d, want 0", svc.calls)
+	}
+	if n := pending(t, rdb); n != 0 {
+		t.Errorf("pending entries = %d, want 0", n)
+	}
+	if n, err := rdb.XLen(ctx, testStream.DeadLetterKey).Result(); err != nil || n != 2 {
+		t.Errorf("dead-lettered entries = %d, %v, want 2", n, err)
+	}
+}
+
+func TestProducerFallback(t *testing.T) {
+	mr, rdb := newRedis(t)
+	fallback := &fakeService{}
+	p := NewProducer(rdb, testStream, fallback)
+
+	mr.Close()
+
+	events := []model.Analytics{{Alias: "a"}, {Alias: "b"}}
+	if err := p.SaveAnalyticsBatch(context.Background(), retry.Strategy{}, events); err != nil {
+		t.Fatalf("SaveAnalyticsBatch() error = %v", err)
+	}
+	if got := fallback.aliases(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("saved by fallback = %v, want [a b]", got)
+	}
+}
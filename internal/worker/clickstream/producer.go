@@ -0,0 +1,126 @@
+// Package clickstream carries click events through a Redis Stream: the producer appends
+// batches prepared by the ingest workers and the consumer saves them from a consumer group.
+package clickstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// eventField is the stream entry field holding the JSON encoded event.
+const eventField = "event"
+
+// Producer appends click events to the stream. It takes the place of the analytics
+// service behind the ingest pipeline, so only prepared events reach the stream.
+type Producer struct {
+	rdb      *redis.Client
+	cfg      config.Stream
+	fallback analyticsService
+}
+
+// NewProducer creates a new Producer instance. Events that cannot be appended are saved
+// directly by the fallback service.
+func NewProducer(rdb *redis.Client, cfg config.Stream, fallback analyticsService) *Producer {
+	return &Producer{rdb: rdb, cfg: withDefaults(cfg), fallback: fallback}
+}
+
+// SaveAnalyticsBatch appends the events to the stream, to be saved by the workers.
+// Events that cannot be appended, e.g. while Redis is unavailable, are saved directly.
+func (p *Producer) SaveAnalyticsBatch(ctx context.Context, strategy retry.Strategy, events []model.Analytics) error {
+	addCtx, cancel := context.WithTimeout(ctx, p.cfg.AddTimeout)
+	defer cancel()
+
+	failed, err := p.add(addCtx, events)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	zlog.Logger.Error().Err(err).Int("events", len(failed)).Msg("failed to append click events to stream, saving them")
+	return p.fallback.SaveAnalyticsBatch(ctx, strategy, failed)
+}
+
+// add appends the events to the stream in a pipeline, trimming it to about the configured
+// length, and returns the events that were not appended with the first error.
+func (p *Producer) add(ctx context.Context, events []model.Analytics) ([]model.Analytics, error) {
+	cmds := make([]*goredis.StringCmd, len(events))
+	encodeErrs := make([]error, len(events))
+
+	_, err := p.rdb.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, event := range events {
+			b, err := json.Marshal(event)
+			if err != nil {
+				encodeErrs[i] = fmt.Errorf("encode event: %w", err)
+				continue
+			}
+
+			cmds[i] = pipe.XAdd(ctx, &goredis.XAddArgs{
+				Stream: p.cfg.Key,
+				MaxLen: p.cfg.MaxLen,
+				Approx: true,
+				Values: map[string]interface{}{eventField: string(b)},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("add to stream: %w", err)
+	}
+
+	var failed []model.Analytics
+	for i, event := range events {
+		switch {
+		case encodeErrs[i] != nil:
+			err = encodeErrs[i]
+		case cmds[i].Err() == nil:
+			continue
+		}
+
+		failed = append(failed, event)
+	}
+
+	return failed, err
+}
+
+// Defaults of settings missing in the configuration.
+const (
+	defaultAddTimeout    = 100 * time.Millisecond
+	defaultBatchSize     = 100
+	defaultBlock         = 5 * time.Second
+	defaultRetryInterval = 30 * time.Second
+	defaultMaxDeliveries = 5
+	defaultWriteTimeout  = 10 * time.Second
+)
+
+// withDefaults returns the configuration with defaults of missing settings.
+func withDefaults(cfg config.Stream) config.Stream {
+	if cfg.AddTimeout <= 0 {
+		cfg.AddTimeout = defaultAddTimeout
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = defaultBlock
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = defaultMaxDeliveries
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+
+	return cfg
+}
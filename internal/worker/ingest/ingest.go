@@ -0,0 +1,252 @@
+// Package ingest buffers click events in memory, prepares and saves them in batches.
+package ingest
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// Policies applied to events arriving while the queue is full.
+const (
+	PolicyDropNewest = "drop_newest" // discard the arriving event
+	PolicyDropOldest = "drop_oldest" // discard the oldest queued event to make room
+	PolicyBlock      = "block"       // wait for room up to the block timeout, then discard the event
+)
+
+// Defaults of intervals missing in the configuration.
+const (
+	defaultFlushInterval = time.Second
+	defaultWriteTimeout  = 10 * time.Second
+)
+
+// clickPreparer defines the interface for enriching and anonymizing queued events.
+type clickPreparer interface {
+	PrepareClick(ctx context.Context, event model.Analytics) model.Analytics
+}
+
+// analyticsService defines the interface that the Pipeline depends on.
+type analyticsService interface {
+	SaveAnalyticsBatch(ctx context.Context, strategy retry.Strategy, events []model.Analytics) error
+}
+
+// Pipeline is a bounded queue of click events drained by a pool of workers, each preparing
+// and saving events in batches of up to the configured size or every flush interval.
+// Events are prepared by the workers rather than when queued, so that lookups needing I/O
+// stay off the redirect path; raw events are held only in memory.
+type Pipeline struct {
+	preparer         clickPreparer
+	analyticsService analyticsService
+	cfg              config.Ingest
+	strategy         retry.Strategy
+
+	queue chan model.Analytics
+
+	mu     sync.RWMutex // guards closed against sends on the closed queue
+	closed bool
+	wg     sync.WaitGroup
+
+	stats *expvar.Map
+}
+
+// New creates a new Pipeline instance and publishes its metrics as the "ingest" expvar:
+// queue_depth, queue_capacity, enqueued, dropped, saved, failed and batches. Batches
+// prepared by cp are saved by as, e.g. the analytics service or a stream producer.
+func New(cp clickPreparer, as analyticsService, cfg config.Ingest, strategy retry.Strategy) *Pipeline {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+
+	// The metrics of a process are those of its latest pipeline.
+	stats, ok := expvar.Get("ingest").(*expvar.Map)
+	if !ok {
+		stats = expvar.NewMap("ingest")
+	}
+
+	p := &Pipeline{
+		preparer:         cp,
+		analyticsService: as,
+		cfg:              cfg,
+		strategy:         strategy,
+		queue:            make(chan model.Analytics, max(cfg.QueueSize, 1)),
+		stats:            stats,
+	}
+
+	p.stats.Set("queue_depth", expvar.Func(func() any { return len(p.queue) }))
+	p.stats.Set("queue_capacity", expvar.Func(func() any { return cap(p.queue) }))
+
+	return p
+}
+
+// Enqueue queues the event according to the configured policy and reports whether it
+// was accepted. Events are discarded once the pipeline is closed.
+func (p *Pipeline) Enqueue(event model.Analytics) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed || !p.push(event) {
+		p.stats.Add("dropped", 1)
+		return false
+	}
+
+	p.stats.Add("enqueued", 1)
+	return true
+}
+
+// push puts the event into the queue, applying the policy if it is full.
+func (p *Pipeline) push(event model.Analytics) bool {
+	select {
+	case p.queue <- event:
+		return true
+	default:
+	}
+
+	switch p.cfg.Policy {
+	case PolicyDropOldest:
+		for {
+			select {
+			case p.queue <- event:
+				return true
+			default:
+			}
+
+			select {
+			case <-p.queue:
+				p.stats.Add("dropped", 1)
+			default:
+			}
+		}
+	case PolicyBlock:
+		timer := time.NewTimer(p.cfg.BlockTimeout)
+		defer timer.Stop()
+
+		select {
+		case p.queue <- event:
+			return true
+		case <-timer.C:
+			return false
+		}
+	default:
+		return false
+	}
+}
+
+// Start starts the workers.
+func (p *Pipeline) Start() {
+	for i := 0; i < max(p.cfg.Workers, 1); i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// Close stops accepting events and waits until the workers have saved the queued ones
+// or ctx is done.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush click events: %w", ctx.Err())
+	}
+}
+
+// work collects events into batches and saves them until the queue is closed and drained.
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+
+	size := max(p.cfg.BatchSize, 1)
+	batch := make([]model.Analytics, 0, size)
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+
+			batch = append(batch, event)
+			if len(batch) == size {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush prepares and saves the batch. If the batch fails after retries, e.g. due to a
+// single invalid event, its events are saved one by one, and only the failing ones are lost.
+func (p *Pipeline) flush(batch []model.Analytics) {
+	if len(batch) == 0 {
+		return
+	}
+
+	p.stats.Add("batches", 1)
+	p.prepare(batch)
+
+	err := p.save(batch)
+	if err == nil {
+		p.stats.Add("saved", int64(len(batch)))
+		return
+	}
+
+	zlog.Logger.Warn().Err(err).Int("events", len(batch)).Msg("failed to save click events, saving one by one")
+
+	for _, event := range batch {
+		if err := p.save([]model.Analytics{event}); err != nil {
+			p.stats.Add("failed", 1)
+			zlog.Logger.Error().Err(err).Str("alias", event.Alias).Msg("failed to save click event")
+			continue
+		}
+
+		p.stats.Add("saved", 1)
+	}
+}
+
+// prepare enriches and anonymizes the events in place within the write timeout.
+func (p *Pipeline) prepare(events []model.Analytics) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.WriteTimeout)
+	defer cancel()
+
+	for i := range events {
+		events[i] = p.preparer.PrepareClick(ctx, events[i])
+	}
+}
+
+// save saves the events within the write timeout.
+func (p *Pipeline) save(events []model.Analytics) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.WriteTimeout)
+	defer cancel()
+
+	return p.analyticsService.SaveAnalyticsBatch(ctx, p.strategy, events)
+}
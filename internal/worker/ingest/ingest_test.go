@@ -0,0 +1,216 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+	"github.com/aliskhannn/url-shortener/internal/model"
+)
+
+// fakeService prepares events by setting their visitor ID and records saved batches by
+// alias. Saves wait for release if it is set, and batches containing an unprepared event
+// or the alias bad fail.
+type fakeService struct {
+	mu      sync.Mutex
+	batches [][]string
+	release chan struct{}
+	bad     string
+}
+
+func (s *fakeService) PrepareClick(_ context.Context, event model.Analytics) model.Analytics {
+	event.VisitorID = "visitor of " + event.Alias
+	return event
+}
+
+func (s *fakeService) SaveAnalyticsBatch(ctx context.Context, _ retry.Strategy, events []model.Analytics) error {
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	aliases := make([]string, 0, len(events))
+	for _, e := range events {
+		if e.VisitorID != "visitor of "+e.Alias {
+			return errors.New("unprepared event")
+		}
+		if s.bad != "" && e.Alias == s.bad {
+			return errors.New("invalid event")
+		}
+		aliases = append(aliases, e.Alias)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, aliases)
+	return nil
+}
+
+// saved returns the aliases of saved events in the order of batches.
+func (s *fakeService) saved() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Concat(s.batches...)
+}
+
+// queued returns the aliases of queued events without dequeuing them for good.
+func queued(p *Pipeline) []string {
+	var aliases []string
+	for range len(p.queue) {
+		e := <-p.queue
+		aliases = append(aliases, e.Alias)
+		p.queue <- e
+	}
+	return aliases
+}
+
+func enqueue(t *testing.T, p *Pipeline, aliases ...string) []bool {
+	t.Helper()
+
+	accepted := make([]bool, 0, len(aliases))
+	for _, a := range aliases {
+		accepted = append(accepted, p.Enqueue(model.Analytics{Alias: a}))
+	}
+	return accepted
+}
+
+func TestEnqueuePolicies(t *testing.T) {
+	tests := []struct {
+		policy       string
+		wantAccepted []bool
+		wantQueued   []string
+	}{
+		{PolicyDropNewest, []bool{true, true, false}, []string{"a", "b"}},
+		{PolicyDropOldest, []bool{true, true, true}, []string{"b", "c"}},
+		{PolicyBlock, []bool{true, true, false}, []string{"a", "b"}},
+		{"", []bool{true, true, false}, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			svc := &fakeService{}
+			cfg := config.Ingest{QueueSize: 2, Policy: tt.policy, BlockTimeout: 10 * time.Millisecond}
+			p := New(svc, svc, cfg, retry.Strategy{})
+
+			if got := enqueue(t, p, "a", "b", "c"); !slices.Equal(got, tt.wantAccepted) {
+				t.Errorf("Enqueue() = %v, want %v", got, tt.wantAccepted)
+			}
+			if got := queued(p); !slices.Equal(got, tt.wantQueued) {
+				t.Errorf("queued = %v, want %v", got, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestEnqueueBlockWaitsForRoom(t *testing.T) {
+	svc := &fakeService{}
+	cfg := config.Ingest{QueueSize: 1, Policy: PolicyBlock, BlockTimeout: 5 * time.Second}
+	p := New(svc, svc, cfg, retry.Strategy{})
+	enqueue(t, p, "a")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-p.queue
+	}()
+
+	if !p.Enqueue(model.Analytics{Alias: "b"}) {
+		t.Fatal("Enqueue() = false, want the event accepted once there is room")
+	}
+	if got := queued(p); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("queued = %v, want [b]", got)
+	}
+}
+
+func TestCloseFlushesQueue(t *testing.T) {
+	svc := &fakeService{}
+	cfg := config.Ingest{QueueSize: 10, Workers: 2, BatchSize: 4, FlushInterval: time.Hour}
+	p := New(svc, svc, cfg, retry.Strategy{})
+	p.Start()
+
+	events := []string{"a", "b", "c", "d", "e"}
+	enqueue(t, p, events...)
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	got := svc.saved()
+	slices.Sort(got)
+	if !slices.Equal(got, events) {
+		t.Errorf("saved = %v, want %v", got, events)
+	}
+
+	if p.Enqueue(model.Analytics{Alias: "f"}) {
+		t.Error("Enqueue() after Close = true, want false")
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestCloseStopsWaitingOnContext(t *testing.T) {
+	svc := &fakeService{release: make(chan struct{})}
+	p := New(svc, svc, config.Ingest{QueueSize: 10, Workers: 1, WriteTimeout: time.Minute}, retry.Strategy{})
+	p.Start()
+	enqueue(t, p, "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(svc.release)
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := svc.saved(); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("saved = %v, want [a]", got)
+	}
+}
+
+func TestFlushIntervalSavesIncompleteBatch(t *testing.T) {
+	svc := &fakeService{}
+	p := New(svc, svc, config.Ingest{QueueSize: 10, BatchSize: 100, FlushInterval: 10 * time.Millisecond}, retry.Strategy{})
+	p.Start()
+	defer p.Close(context.Background())
+
+	enqueue(t, p, "a")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(svc.saved()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("incomplete batch not saved after the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFailedBatchSavedOneByOne(t *testing.T) {
+	svc := &fakeService{bad: "bad"}
+	p := New(svc, svc, config.Ingest{QueueSize: 10, BatchSize: 3, FlushInterval: time.Hour}, retry.Strategy{})
+	p.Start()
+
+	enqueue(t, p, "a", "bad", "b")
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	want := [][]string{{"a"}, {"b"}}
+	if !slices.EqualFunc(svc.batches, want, slices.Equal) {
+		t.Errorf("batches = %v, want %v", svc.batches, want)
+	}
+}
@@ -0,0 +1,73 @@
+package partitions
+
+import (
+	"context"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+)
+
+// analyticsService defines the interface that the Manager depends on.
+type analyticsService interface {
+	EnsurePartitions(ctx context.Context, now time.Time, ahead int) error
+	ExpirePartitions(ctx context.Context, mode string, cutoff time.Time) ([]time.Time, error)
+}
+
+// Manager periodically creates future monthly partitions of clicks and archives or drops
+// partitions older than the retention period.
+type Manager struct {
+	analyticsService analyticsService
+	cfg              config.Partitions
+}
+
+// New creates a new Manager instance.
+func New(as analyticsService, cfg config.Partitions) *Manager {
+	return &Manager{analyticsService: as, cfg: cfg}
+}
+
+// Run manages partitions at once and then every configured interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	if m.cfg.Interval <= 0 {
+		zlog.Logger.Error().Msg("analytics partition manager is disabled, " +
+			"clicks of months without a partition pile up in the default partition and are never expired")
+		return
+	}
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.manage(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// manage creates missing partitions and expires old ones.
+func (m *Manager) manage(ctx context.Context) {
+	now := time.Now()
+
+	if err := m.analyticsService.EnsurePartitions(ctx, now, m.cfg.Ahead); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to create analytics partitions")
+	}
+
+	if m.cfg.RetentionMonths <= 0 {
+		return // clicks are kept forever
+	}
+
+	cutoff := now.AddDate(0, -m.cfg.RetentionMonths, 0)
+
+	expired, err := m.analyticsService.ExpirePartitions(ctx, m.cfg.Mode, cutoff)
+	for _, month := range expired {
+		zlog.Logger.Info().Str("month", month.Format("2006-01")).Str("mode", m.cfg.Mode).Msg("expired analytics partition")
+	}
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("mode", m.cfg.Mode).Msg("failed to expire analytics partitions")
+	}
+}
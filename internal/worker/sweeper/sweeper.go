@@ -0,0 +1,69 @@
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/url-shortener/internal/config"
+)
+
+// linkService defines the interface that the Sweeper depends on.
+type linkService interface {
+	SweepExpiredLinks(ctx context.Context, mode string, cutoff time.Time, batchSize int) (int64, error)
+}
+
+// Sweeper periodically archives or deletes expired links.
+type Sweeper struct {
+	linkService linkService
+	cfg         config.Sweeper
+}
+
+// New creates a new Sweeper instance.
+func New(ls linkService, cfg config.Sweeper) *Sweeper {
+	return &Sweeper{linkService: ls, cfg: cfg}
+}
+
+// Run sweeps expired links every configured interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	if s.cfg.Interval <= 0 || s.cfg.BatchSize <= 0 {
+		zlog.Logger.Warn().Msg("expired links sweeper is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+// sweep processes expired links in batches until none are left.
+func (s *Sweeper) sweep(ctx context.Context) {
+	cutoff := time.Now().Add(-s.cfg.GracePeriod)
+
+	var total int64
+	for ctx.Err() == nil {
+		n, err := s.linkService.SweepExpiredLinks(ctx, s.cfg.Mode, cutoff, s.cfg.BatchSize)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("mode", s.cfg.Mode).Msg("failed to sweep expired links")
+			return
+		}
+
+		total += n
+		if n < int64(s.cfg.BatchSize) {
+			break // no more expired links
+		}
+	}
+
+	if total > 0 {
+		zlog.Logger.Info().Int64("count", total).Str("mode", s.cfg.Mode).Msg("swept expired links")
+	}
+}
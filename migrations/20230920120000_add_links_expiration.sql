@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN expires_at  TIMESTAMP,
+    ADD COLUMN archived_at TIMESTAMP;
+
+CREATE INDEX idx_links_expires_at ON links (expires_at) WHERE expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_links_expires_at;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS archived_at,
+    DROP COLUMN IF EXISTS expires_at;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN enabled    BOOLEAN   NOT NULL DEFAULT TRUE,
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE links
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS enabled;
+-- +goose StatementEnd
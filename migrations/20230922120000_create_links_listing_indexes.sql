@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_links_created_at_id ON links (created_at, id);
+CREATE INDEX idx_links_alias_pattern ON links (alias varchar_pattern_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_links_alias_pattern;
+DROP INDEX IF EXISTS idx_links_created_at_id;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_keys
+(
+    id           UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    owner_id     UUID        NOT NULL,
+    name         VARCHAR(64) NOT NULL DEFAULT '',
+    prefix       VARCHAR(16) NOT NULL,
+    key_hash     CHAR(64)    NOT NULL UNIQUE,
+    created_at   TIMESTAMP   NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_owner_id ON api_keys (owner_id);
+
+-- Links created before API keys were introduced have no owner and can only be resolved
+-- through public redirects until an administrator assigns them to an owner.
+ALTER TABLE links
+    ADD COLUMN owner_id UUID;
+
+CREATE INDEX idx_links_owner_id ON links (owner_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_links_owner_id;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
@@ -0,0 +1,98 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE workspaces
+(
+    id         UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    slug         VARCHAR(32)  NOT NULL UNIQUE,
+    name         VARCHAR(128) NOT NULL DEFAULT '',
+    owner_scoped BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE workspace_members
+(
+    workspace_id UUID        NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id      UUID        NOT NULL,
+    role         VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at   TIMESTAMP   NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX idx_workspace_members_user_id ON workspace_members (user_id);
+
+-- Existing links and API key owners move to the default workspace, keeping aliases and
+-- their public redirects. Its members are unrelated tenants, so it is owner-scoped: each
+-- member sees and manages only the links they created, and nobody manages its members.
+INSERT INTO workspaces (slug, name, owner_scoped)
+VALUES ('default', 'Default', TRUE);
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT w.id, k.owner_id, 'editor'
+FROM workspaces w
+         CROSS JOIN (SELECT DISTINCT owner_id FROM api_keys) k
+WHERE w.slug = 'default';
+
+ALTER TABLE links
+    ADD COLUMN workspace_id UUID REFERENCES workspaces (id) ON DELETE CASCADE;
+
+UPDATE links
+SET workspace_id = (SELECT id FROM workspaces WHERE slug = 'default');
+
+ALTER TABLE links
+    ALTER COLUMN workspace_id SET NOT NULL;
+
+-- Analytics reference links by id, since aliases are only unique within a workspace.
+ALTER TABLE analytics
+    ADD COLUMN link_id      UUID,
+    ADD COLUMN workspace_id UUID;
+
+UPDATE analytics a
+SET link_id      = l.id,
+    workspace_id = l.workspace_id
+FROM links l
+WHERE l.alias = a.alias;
+
+ALTER TABLE analytics
+    DROP CONSTRAINT analytics_alias_fkey,
+    ALTER COLUMN link_id SET NOT NULL,
+    ALTER COLUMN workspace_id SET NOT NULL,
+    ADD CONSTRAINT analytics_link_id_fkey FOREIGN KEY (link_id) REFERENCES links (id) ON DELETE CASCADE;
+
+CREATE INDEX idx_analytics_workspace_link ON analytics (workspace_id, link_id, created_at);
+
+ALTER TABLE links
+    DROP CONSTRAINT links_alias_key,
+    ADD CONSTRAINT links_workspace_alias_key UNIQUE (workspace_id, alias);
+
+-- Links are always listed within a workspace.
+DROP INDEX IF EXISTS idx_links_created_at_id;
+DROP INDEX IF EXISTS idx_links_alias_pattern;
+CREATE INDEX idx_links_workspace_created_at_id ON links (workspace_id, created_at, id);
+CREATE INDEX idx_links_workspace_alias_pattern ON links (workspace_id, alias varchar_pattern_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_links_workspace_alias_pattern;
+DROP INDEX IF EXISTS idx_links_workspace_created_at_id;
+CREATE INDEX idx_links_created_at_id ON links (created_at, id);
+CREATE INDEX idx_links_alias_pattern ON links (alias varchar_pattern_ops);
+
+ALTER TABLE links
+    DROP CONSTRAINT links_workspace_alias_key,
+    ADD CONSTRAINT links_alias_key UNIQUE (alias);
+
+DROP INDEX IF EXISTS idx_analytics_workspace_link;
+
+ALTER TABLE analytics
+    DROP CONSTRAINT analytics_link_id_fkey,
+    ADD CONSTRAINT analytics_alias_fkey FOREIGN KEY (alias) REFERENCES links (alias) ON DELETE CASCADE,
+    DROP COLUMN workspace_id,
+    DROP COLUMN link_id;
+
+ALTER TABLE links
+    DROP COLUMN workspace_id;
+
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE domains
+(
+    id                 UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    workspace_id       UUID         NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    hostname           VARCHAR(253) NOT NULL,
+    verification_token VARCHAR(64)  NOT NULL,
+    verified_at        TIMESTAMP,
+    created_at         TIMESTAMP    NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, hostname)
+);
+
+-- A hostname can be claimed by several workspaces, but verified by one only.
+CREATE UNIQUE INDEX idx_domains_verified_hostname ON domains (hostname) WHERE verified_at IS NOT NULL;
+
+-- Empty domain stands for the shared default host.
+ALTER TABLE links
+    ADD COLUMN domain VARCHAR(253) NOT NULL DEFAULT '';
+
+ALTER TABLE links
+    DROP CONSTRAINT links_workspace_alias_key,
+    ADD CONSTRAINT links_workspace_domain_alias_key UNIQUE (workspace_id, domain, alias);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE links
+    DROP CONSTRAINT links_workspace_domain_alias_key,
+    ADD CONSTRAINT links_workspace_alias_key UNIQUE (workspace_id, alias);
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS domain;
+
+DROP TABLE IF EXISTS domains;
+-- +goose StatementEnd
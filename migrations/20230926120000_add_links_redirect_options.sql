@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN redirect_code   SMALLINT    NOT NULL DEFAULT 302
+        CHECK (redirect_code IN (301, 302, 307, 308)),
+    ADD COLUMN redirect_mode   VARCHAR(8)  NOT NULL DEFAULT 'http'
+        CHECK (redirect_mode IN ('http', 'meta', 'js')),
+    ADD COLUMN tracking_pixels TEXT[]      NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE links
+    DROP COLUMN IF EXISTS tracking_pixels,
+    DROP COLUMN IF EXISTS redirect_mode,
+    DROP COLUMN IF EXISTS redirect_code;
+-- +goose StatementEnd
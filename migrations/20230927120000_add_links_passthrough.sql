@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN query_passthrough VARCHAR(8) NOT NULL DEFAULT 'off'
+        CHECK (query_passthrough IN ('off', 'merge', 'override')),
+    ADD COLUMN path_passthrough  BOOLEAN    NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE links
+    DROP COLUMN IF EXISTS path_passthrough,
+    DROP COLUMN IF EXISTS query_passthrough;
+-- +goose StatementEnd
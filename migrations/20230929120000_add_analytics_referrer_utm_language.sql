@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE analytics
+    ADD COLUMN referrer        TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN referrer_domain VARCHAR(253) NOT NULL DEFAULT '',
+    ADD COLUMN utm_source      VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN utm_medium      VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN utm_campaign    VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN utm_term        VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN utm_content     VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN language        VARCHAR(35)  NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS utm_content,
+    DROP COLUMN IF EXISTS utm_term,
+    DROP COLUMN IF EXISTS utm_campaign,
+    DROP COLUMN IF EXISTS utm_medium,
+    DROP COLUMN IF EXISTS utm_source,
+    DROP COLUMN IF EXISTS referrer_domain,
+    DROP COLUMN IF EXISTS referrer;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE analytics
+    ADD COLUMN country VARCHAR(2)   NOT NULL DEFAULT '',
+    ADD COLUMN region  VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN city    VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN asn     BIGINT       NOT NULL DEFAULT 0,
+    ADD COLUMN as_org  VARCHAR(255) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS as_org,
+    DROP COLUMN IF EXISTS asn,
+    DROP COLUMN IF EXISTS city,
+    DROP COLUMN IF EXISTS region,
+    DROP COLUMN IF EXISTS country;
+-- +goose StatementEnd
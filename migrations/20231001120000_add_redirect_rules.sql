@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN rules JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE analytics
+    ADD COLUMN rule VARCHAR(64) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS rule;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS rules;
+-- +goose StatementEnd
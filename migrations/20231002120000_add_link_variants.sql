@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN variants JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE analytics
+    ADD COLUMN variant VARCHAR(64) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS variant;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS variants;
+-- +goose StatementEnd
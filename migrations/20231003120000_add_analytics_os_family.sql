@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE analytics
+    ADD COLUMN os_family VARCHAR(16) NOT NULL DEFAULT '';
+
+UPDATE analytics
+SET os_family = CASE
+    WHEN os ILIKE '%iphone%' OR os ILIKE '%ipad%' OR os ILIKE '%ipod%' THEN 'ios'
+    WHEN os ILIKE '%android%' THEN 'android'
+    WHEN os ILIKE '%windows%' THEN 'windows'
+    WHEN os ILIKE '%mac os x%' THEN 'macos'
+    WHEN os ILIKE '%cros%' THEN 'chromeos'
+    WHEN os ILIKE '%linux%' THEN 'linux'
+    ELSE ''
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS os_family;
+-- +goose StatementEnd
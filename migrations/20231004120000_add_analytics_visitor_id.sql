@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE analytics
+    ADD COLUMN visitor_id VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_analytics_link_visitor ON analytics (link_id, visitor_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_analytics_link_visitor;
+
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS visitor_id;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE analytics
+    ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN bot_reason VARCHAR(32) NOT NULL DEFAULT '';
+
+UPDATE analytics
+SET is_bot = TRUE, bot_reason = 'user_agent'
+WHERE device_type = 'bot';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS bot_reason,
+    DROP COLUMN IF EXISTS is_bot;
+-- +goose StatementEnd
@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE analytics_hourly
+(
+    link_id      UUID        NOT NULL REFERENCES links (id) ON DELETE CASCADE,
+    workspace_id UUID        NOT NULL,
+    bucket       TIMESTAMP   NOT NULL,
+    dimension    VARCHAR(16) NOT NULL,
+    value        TEXT        NOT NULL,
+    is_bot       BOOLEAN     NOT NULL,
+    clicks       BIGINT      NOT NULL,
+    PRIMARY KEY (link_id, bucket, dimension, value, is_bot)
+);
+
+CREATE TABLE analytics_daily
+(
+    link_id      UUID        NOT NULL REFERENCES links (id) ON DELETE CASCADE,
+    workspace_id UUID        NOT NULL,
+    bucket       TIMESTAMP   NOT NULL,
+    dimension    VARCHAR(16) NOT NULL,
+    value        TEXT        NOT NULL,
+    is_bot       BOOLEAN     NOT NULL,
+    clicks       BIGINT      NOT NULL,
+    PRIMARY KEY (link_id, bucket, dimension, value, is_bot)
+);
+
+CREATE INDEX idx_analytics_hourly_dimension ON analytics_hourly (link_id, dimension, bucket);
+CREATE INDEX idx_analytics_daily_dimension ON analytics_daily (link_id, dimension, bucket);
+
+CREATE TEMPORARY TABLE analytics_dimensions ON COMMIT DROP AS
+SELECT a.workspace_id, a.link_id, a.created_at, a.is_bot, d.dimension, d.value
+FROM analytics a
+         CROSS JOIN LATERAL (VALUES ('total', ''),
+                                    ('source', a.source),
+                                    ('referrer', a.referrer_domain),
+                                    ('campaign', a.utm_campaign),
+                                    ('language', a.language),
+                                    ('country', a.country),
+                                    ('city', CASE WHEN a.city = '' THEN '' ELSE a.city || ', ' || a.country END),
+                                    ('rule', a.rule),
+                                    ('variant', a.variant),
+                                    ('device', COALESCE(a.device_type, '')),
+                                    ('os', a.os_family),
+                                    ('browser', COALESCE(a.browser, ''))) AS d (dimension, value)
+WHERE d.value <> '' OR d.dimension IN ('total', 'source');
+
+INSERT INTO analytics_hourly (link_id, workspace_id, bucket, dimension, value, is_bot, clicks)
+SELECT link_id, workspace_id, date_trunc('hour', created_at), dimension, value, is_bot, COUNT(*)
+FROM analytics_dimensions
+GROUP BY 1, 2, 3, 4, 5, 6;
+
+INSERT INTO analytics_daily (link_id, workspace_id, bucket, dimension, value, is_bot, clicks)
+SELECT link_id, workspace_id, date_trunc('day', created_at), dimension, value, is_bot, COUNT(*)
+FROM analytics_dimensions
+GROUP BY 1, 2, 3, 4, 5, 6;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS analytics_daily;
+DROP TABLE IF EXISTS analytics_hourly;
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE analytics_partitioned
+(
+    LIKE analytics INCLUDING DEFAULTS
+) PARTITION BY RANGE (created_at);
+
+-- Create monthly partitions from the oldest click through three months ahead,
+-- later months are created by the partition manager.
+DO
+$$
+    DECLARE
+        month TIMESTAMP := date_trunc('month', COALESCE((SELECT MIN(created_at) FROM analytics), NOW()::timestamp));
+    BEGIN
+        WHILE month <= date_trunc('month', NOW()::timestamp) + INTERVAL '3 months'
+            LOOP
+                EXECUTE format(
+                        'CREATE TABLE %I PARTITION OF analytics_partitioned FOR VALUES FROM (%L) TO (%L)',
+                        'analytics_' || to_char(month, 'YYYYMM'), month, month + INTERVAL '1 month'
+                        );
+                month := month + INTERVAL '1 month';
+            END LOOP;
+    END
+$$;
+
+-- Clicks outside the monthly partitions, e.g. redelivered old events or clicks of months the
+-- partition manager has not created, land here instead of failing the insert. The manager
+-- moves them to the partition of their month once it creates it.
+CREATE TABLE analytics_default PARTITION OF analytics_partitioned DEFAULT;
+
+INSERT INTO analytics_partitioned
+SELECT *
+FROM analytics;
+
+DROP TABLE analytics;
+
+ALTER TABLE analytics_partitioned
+    RENAME TO analytics;
+
+ALTER TABLE analytics
+    ADD CONSTRAINT analytics_pkey PRIMARY KEY (id, created_at),
+    ADD CONSTRAINT analytics_link_id_fkey FOREIGN KEY (link_id) REFERENCES links (id) ON DELETE CASCADE;
+
+-- idx_analytics_alias and idx_analytics_created_at are not recreated: clicks are read by
+-- link_id since workspaces were added, and time ranges are pruned by partitions and served
+-- by idx_analytics_workspace_link.
+CREATE INDEX idx_analytics_workspace_link ON analytics (workspace_id, link_id, created_at);
+CREATE INDEX idx_analytics_link_visitor ON analytics (link_id, visitor_id);
+
+-- Partitions detached by the retention policy in the archive mode.
+CREATE SCHEMA IF NOT EXISTS analytics_archive;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE analytics_plain
+(
+    LIKE analytics INCLUDING DEFAULTS
+);
+
+INSERT INTO analytics_plain
+SELECT *
+FROM analytics;
+
+DROP TABLE analytics;
+
+ALTER TABLE analytics_plain
+    RENAME TO analytics;
+
+ALTER TABLE analytics
+    ADD CONSTRAINT analytics_pkey PRIMARY KEY (id),
+    ADD CONSTRAINT analytics_link_id_fkey FOREIGN KEY (link_id) REFERENCES links (id) ON DELETE CASCADE;
+
+CREATE INDEX idx_analytics_alias ON analytics (alias);
+CREATE INDEX idx_analytics_created_at ON analytics (created_at);
+CREATE INDEX idx_analytics_workspace_link ON analytics (workspace_id, link_id, created_at);
+CREATE INDEX idx_analytics_link_visitor ON analytics (link_id, visitor_id);
+-- +goose StatementEnd
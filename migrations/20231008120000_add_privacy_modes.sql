@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN privacy_mode VARCHAR(16) NOT NULL DEFAULT '';
+
+ALTER TABLE analytics
+    ADD COLUMN ip_hash VARCHAR(32) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analytics
+    DROP COLUMN IF EXISTS ip_hash;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS privacy_mode;
+-- +goose StatementEnd
@@ -3,8 +3,40 @@ import type { Link } from "../entities/link";
 export interface AnalyticsSummary {
   alias: string;
   total_clicks: number;
-  daily: Record<string, number>;
-  user_agent: Record<string, number>;
+  granularity: string;
+  timezone: string;
+  series: { time: string; clicks: number }[];
+  user_agent?: Record<string, number>;
+}
+
+const API_KEY = "apiKey";
+const WORKSPACE = "workspace";
+
+// Credentials are kept in local storage, so the analytics page opened from the
+// form authenticates with the same key and workspace.
+export interface Credentials {
+  apiKey: string;
+  workspace: string;
+}
+
+export function getCredentials(): Credentials {
+  return {
+    apiKey: localStorage.getItem(API_KEY) ?? "",
+    workspace: localStorage.getItem(WORKSPACE) || "default",
+  };
+}
+
+export function saveCredentials(c: Credentials) {
+  localStorage.setItem(API_KEY, c.apiKey);
+  localStorage.setItem(WORKSPACE, c.workspace);
+}
+
+function authHeaders(): Record<string, string> {
+  const { apiKey, workspace } = getCredentials();
+  return {
+    Authorization: `Bearer ${apiKey}`,
+    "X-Workspace": workspace,
+  };
 }
 
 export async function createLink(req: {
@@ -13,16 +45,20 @@ export async function createLink(req: {
 }): Promise<Link> {
   const res = await fetch("http://localhost:8080/api/shorten", {
     method: "POST",
-    headers: { "Content-Type": "application/json" },
+    headers: { "Content-Type": "application/json", ...authHeaders() },
     body: JSON.stringify(req),
   });
+  if (res.status === 401) throw new Error("Invalid API key");
   if (!res.ok) throw new Error("Failed to create link");
   const data = await res.json();
   return data.result;
 }
 
 export async function getAnalytics(alias: string): Promise<AnalyticsSummary> {
-  const res = await fetch(`http://localhost:8080/api/analytics/${alias}`);
+  const res = await fetch(`http://localhost:8080/api/analytics/${alias}?user_agents=true`, {
+    headers: authHeaders(),
+  });
+  if (res.status === 401) throw new Error("Invalid API key");
   if (!res.ok) throw new Error("Failed to fetch analytics");
   const data = await res.json();
   return data; // возвращаем объект агрегированной статистики
@@ -1,9 +1,11 @@
 import { useState } from "react";
-import { createLink } from "../api/api";
+import { createLink, getCredentials, saveCredentials } from "../api/api";
 
 export const LinkForm = () => {
   const [url, setUrl] = useState("");
   const [alias, setAlias] = useState("");
+  const [apiKey, setApiKey] = useState(getCredentials().apiKey);
+  const [workspace, setWorkspace] = useState(getCredentials().workspace);
   const [result, setResult] = useState<string | null>(null);
   const [analyticsURL, setAnalyticsURL] = useState<string | null>(null);
   const [error, setError] = useState<string | null>(null);
@@ -13,10 +15,12 @@ export const LinkForm = () => {
     setError(null);
     setResult(null);
 
+    saveCredentials({ apiKey, workspace });
+
     try {
       const data = await createLink({ url, alias: alias || undefined });
       console.log(data);
-      setResult(`http://localhost:8080/api/s/${data.alias}`);
+      setResult(data.short_url);
       setAnalyticsURL(`http://localhost:3000/analytics/${data.alias}`);
     } catch (err: any) {
       setError(err.message);
@@ -42,6 +46,22 @@ export const LinkForm = () => {
           onChange={(e) => setAlias(e.target.value)}
           className="border p-2 rounded"
         />
+        <input
+          type="password"
+          placeholder="API key"
+          value={apiKey}
+          onChange={(e) => setApiKey(e.target.value)}
+          required
+          className="border p-2 rounded"
+        />
+        <input
+          type="text"
+          placeholder="Workspace"
+          value={workspace}
+          onChange={(e) => setWorkspace(e.target.value)}
+          required
+          className="border p-2 rounded"
+        />
         <button
           type="submit"
           className="bg-blue-500 text-white p-2 rounded hover:bg-blue-600"
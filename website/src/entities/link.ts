@@ -2,5 +2,6 @@ export interface Link {
   id: string           // uuid
   url: string          // original url
   alias: string        // short alias
+  short_url: string    // public short URL
   createdAt: string    // timestamp в ISO формате
 }
\ No newline at end of file
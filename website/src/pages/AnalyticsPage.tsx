@@ -5,8 +5,10 @@ import { getAnalytics } from '../api/api'
 export interface AnalyticsSummary {
   alias: string
   total_clicks: number
-  daily: Record<string, number>
-  user_agent: Record<string, number>
+  granularity: string
+  timezone: string
+  series: { time: string; clicks: number }[]
+  user_agent?: Record<string, number>
 }
 
 interface TableRow {
@@ -35,11 +37,11 @@ export const AnalyticsPage = () => {
   if (!summary) return <p className="p-4">No analytics available</p>
 
   // Преобразуем объекты в массивы для безопасного map
-  const dailyArray: TableRow[] = Object.entries(summary.daily).map(([day, clicks]) => ({
-    label: day,
+  const dailyArray: TableRow[] = summary.series.map(({ time, clicks }) => ({
+    label: time.slice(0, 10),
     value: clicks,
   }))
-  const uaArray: TableRow[] = Object.entries(summary.user_agent).map(([agent, clicks]) => ({
+  const uaArray: TableRow[] = Object.entries(summary.user_agent ?? {}).map(([agent, clicks]) => ({
     label: agent,
     value: clicks,
   }))